@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+//
+// --------------------
+// Admin methods
+// --------------------
+//
+
+// defaultAdminScope is the token scope admin/shutdown requires.
+const defaultAdminScope = "mcp:admin"
+
+// EnableAdmin exposes admin/shutdown, which calls shutdown for a caller whose
+// token carries scope. Only call it when tokens are validated: without
+// claims there's nobody to authorize, so the method is refused.
+func (s *MCPServer) EnableAdmin(scope string, shutdown func()) {
+	s.adminScope = scope
+	s.shutdown = shutdown
+}
+
+// handleAdminShutdown starts a graceful shutdown. The reply is written before
+// the HTTP server stops, since shutdown waits for in-flight requests.
+func (s *MCPServer) handleAdminShutdown(ctx context.Context, id interface{}) JSONRPCResponse {
+	if s.shutdown == nil {
+		// Not advertised when disabled, so don't admit it exists
+		return newMethodNotFound(id, "admin/shutdown")
+	}
+	if !s.isInitialized(sessionFromContext(ctx)) {
+		return s.sendError(id, -32002, "Server not initialized", nil)
+	}
+
+	required := []string{s.adminScope}
+	claims, ok := claimsFromContext(ctx)
+	if !ok {
+		return s.sendError(id, -32003, "Insufficient scope for admin/shutdown", ScopeErrorData{
+			Required: required,
+			Missing:  required,
+		})
+	}
+	if missing := claims.missingScopes(required); len(missing) > 0 {
+		return s.sendError(id, -32003, "Insufficient scope for admin/shutdown", ScopeErrorData{
+			Required: required,
+			Missing:  missing,
+		})
+	}
+
+	log.Printf("Shutdown requested by %s", claims.Subject)
+	s.shutdown()
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  map[string]string{},
+	}
+}
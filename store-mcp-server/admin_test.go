@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAdminShutdown(t *testing.T) {
+	withScope := func(scope string) func(context.Context) context.Context {
+		return func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, claimsContextKey, &TokenClaims{Subject: "ops", Scope: scope})
+		}
+	}
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		initialize   bool
+		auth         func(context.Context) context.Context
+		wantCode     int
+		wantShutdown bool
+	}{
+		{"not enabled", false, true, withScope(defaultAdminScope), -32601, false},
+		{"not initialized", true, false, withScope(defaultAdminScope), -32002, false},
+		{"unauthenticated", true, true, nil, -32003, false},
+		{"missing scope", true, true, withScope("openid profile"), -32003, false},
+		{"authorized", true, true, withScope("openid " + defaultAdminScope), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			var shutdowns int
+			if tt.enabled {
+				s.EnableAdmin(defaultAdminScope, func() { shutdowns++ })
+			}
+			ctx := withSession(context.Background(), newSession())
+			if tt.initialize {
+				ctx = initialized(t, s)
+			}
+			if tt.auth != nil {
+				ctx = tt.auth(ctx)
+			}
+
+			resp := call(t, s, ctx, "admin/shutdown", nil)
+			switch {
+			case tt.wantCode == 0 && resp.Error != nil:
+				t.Errorf("error = %+v, want success", resp.Error)
+			case tt.wantCode != 0 && (resp.Error == nil || resp.Error.Code != tt.wantCode):
+				t.Errorf("error = %+v, want code %d", resp.Error, tt.wantCode)
+			}
+			if got := shutdowns > 0; got != tt.wantShutdown {
+				t.Errorf("shutdown called = %v, want %v", got, tt.wantShutdown)
+			}
+		})
+	}
+}
+
+func TestAdminShutdownCounted(t *testing.T) {
+	s := NewMCPServer()
+	call(t, s, initialized(t, s), "admin/shutdown", nil)
+	if got := s.stats().Methods["admin/shutdown"]; got != 1 {
+		t.Errorf("admin/shutdown count = %d, want 1", got)
+	}
+	for _, m := range supportedMethods() {
+		if m == "admin/shutdown" {
+			t.Error("admin/shutdown advertised in supportedMethods")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//
+// --------------------
+// Tool-call audit log
+// --------------------
+//
+
+// auditLogSize is how many recent tool calls -debug keeps.
+const auditLogSize = 100
+
+// maxAuditArgBytes caps how much of a call's arguments an entry keeps.
+const maxAuditArgBytes = 512
+
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Tool       string    `json:"tool"`
+	Arguments  string    `json:"arguments,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS float64   `json:"durationMs"`
+}
+
+// AuditLog keeps the most recent tool calls in a fixed-size ring buffer.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	next    int
+	full    bool
+}
+
+func NewAuditLog(size int) *AuditLog {
+	if size < 1 {
+		size = 1
+	}
+	return &AuditLog{entries: make([]AuditEntry, size)}
+}
+
+// Record adds an entry, evicting the oldest once the log is full. It is a
+// no-op on a nil log.
+func (a *AuditLog) Record(e AuditEntry) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[a.next] = e
+	a.next = (a.next + 1) % len(a.entries)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// Entries returns the recorded calls, oldest first.
+func (a *AuditLog) Entries() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.full {
+		return append([]AuditEntry{}, a.entries[:a.next]...)
+	}
+	out := make([]AuditEntry, 0, len(a.entries))
+	out = append(out, a.entries[a.next:]...)
+	return append(out, a.entries[:a.next]...)
+}
+
+func (a *AuditLog) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"entries": a.Entries()})
+	})
+}
+
+// summarizeArgs renders arguments as JSON, truncated to maxAuditArgBytes
+// without splitting a multi-byte character.
+func summarizeArgs(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	if len(data) > maxAuditArgBytes {
+		cut := maxAuditArgBytes
+		for cut > 0 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		return string(data[:cut]) + "..."
+	}
+	return string(data)
+}
+
+// SetAuditLog records every executed tool call in a. It must be called
+// before the server starts handling requests.
+func (s *MCPServer) SetAuditLog(a *AuditLog) {
+	s.audit = a
+}
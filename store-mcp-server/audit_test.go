@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestToolCallAudited(t *testing.T) {
+	s, ctx := newStoreServer(t)
+	audit := NewAuditLog(2)
+	s.SetAuditLog(audit)
+
+	callTool(t, s, ctx, "list_indian_stores", nil)
+	callTool(t, s, ctx, "get_store_details", map[string]interface{}{"name": "Nowhere"})
+	callTool(t, s, ctx, "search_stores", map[string]interface{}{"query": "fashion"})
+
+	rec := httptest.NewRecorder()
+	audit.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/audit", nil))
+	var body struct {
+		Entries []AuditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+
+	// The log holds two entries, so the first call has been evicted
+	want := []struct{ tool, outcome string }{
+		{"get_store_details", "error"},
+		{"search_stores", "success"},
+	}
+	if len(body.Entries) != len(want) {
+		t.Fatalf("entries = %+v, want %d", body.Entries, len(want))
+	}
+	for i, w := range want {
+		e := body.Entries[i]
+		if e.Tool != w.tool || e.Outcome != w.outcome || e.Time.IsZero() || e.Arguments == "" {
+			t.Errorf("entry %d = %+v, want %s with outcome %s", i, e, w.tool, w.outcome)
+		}
+	}
+}
+
+func TestSummarizeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    string
+		wantCut bool
+	}{
+		{"none", nil, "", false},
+		{"short", map[string]interface{}{"name": "Myntra"}, `{"name":"Myntra"}`, false},
+		{"long ASCII", map[string]interface{}{"q": strings.Repeat("a", 1000)}, "", true},
+		// Odd padding moves the cut into the middle of a three-byte rune
+		{"long multi-byte", map[string]interface{}{"q": "x" + strings.Repeat("₹", 400)}, "", true},
+		{"long multi-byte shifted", map[string]interface{}{"q": "xx" + strings.Repeat("₹", 400)}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarizeArgs(tt.args)
+			if !utf8.ValidString(got) {
+				t.Errorf("summary is not valid UTF-8: %q", got)
+			}
+			if !tt.wantCut {
+				if got != tt.want {
+					t.Errorf("summary = %q, want %q", got, tt.want)
+				}
+				return
+			}
+			if !strings.HasSuffix(got, "...") || len(got)-len("...") > maxAuditArgBytes {
+				t.Errorf("summary of %d bytes isn't truncated to %d", len(got), maxAuditArgBytes)
+			}
+		})
+	}
+}
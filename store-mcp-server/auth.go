@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//
+// --------------------
+// Bearer authentication
+// --------------------
+//
+
+type contextKey int
+
+const (
+	claimsContextKey contextKey = iota
+	requestInfoContextKey
+	sessionContextKey
+	clientAddrContextKey
+	notifierContextKey
+	progressContextKey
+)
+
+// TokenValidator checks a bearer token and returns its claims.
+type TokenValidator interface {
+	Validate(token string) (*TokenClaims, error)
+}
+
+// JWTValidator verifies Casdoor JWTs locally against the signing keys.
+type JWTValidator struct {
+	keys     keySource
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+func NewJWTValidator(keys keySource, issuer, audience string) *JWTValidator {
+	return &JWTValidator{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		now:      time.Now,
+	}
+}
+
+// Warm fetches the signing keys ahead of the first request, when the key
+// source supports it.
+func (v *JWTValidator) Warm() error {
+	if w, ok := v.keys.(interface{ Warm() error }); ok {
+		return w.Warm()
+	}
+	return nil
+}
+
+func (v *JWTValidator) Validate(token string) (*TokenClaims, error) {
+	claims, err := verifyJWT(token, v.keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.validate(v.issuer, v.audience, v.now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Authenticator guards HTTP handlers with bearer-token authentication.
+type Authenticator struct {
+	validator TokenValidator
+}
+
+func NewAuthenticator(validator TokenValidator) *Authenticator {
+	return &Authenticator{validator: validator}
+}
+
+// middleware rejects requests without a valid bearer token and stores the
+// decoded claims in the request context for downstream handlers.
+func (a *Authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflights never carry credentials
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			unauthorized(w, r, "")
+			return
+		}
+
+		claims, err := a.validator.Validate(token)
+		if err != nil {
+			log.Printf("auth: rejected token: %v", err)
+			unauthorized(w, r, err.Error())
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// unauthorized sends a 401 whose challenge points at our protected-resource
+// metadata, as the MCP authorization spec requires.
+func unauthorized(w http.ResponseWriter, r *http.Request, reason string) {
+	challenge := fmt.Sprintf(`Bearer realm="mcp", resource_metadata=%q`,
+		externalBaseURL(r)+"/.well-known/oauth-protected-resource")
+	if reason != "" {
+		challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, reason)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// claimsFromContext returns the validated token claims, if any.
+func claimsFromContext(ctx context.Context) (*TokenClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*TokenClaims)
+	return claims, ok
+}
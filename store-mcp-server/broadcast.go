@@ -0,0 +1,66 @@
+package main
+
+import "log"
+
+//
+// --------------------
+// List change notifications
+// --------------------
+//
+
+// subscribe registers the notifier of a connection that can receive
+// server-initiated messages (stdio, SSE, WebSocket), so it hears about
+// registry changes. The returned func removes it again.
+func (s *MCPServer) subscribe(sess *Session, notify notifier) func() {
+	s.mu.Lock()
+	s.subscribers[sess] = notify
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.subscribers, sess)
+		s.mu.Unlock()
+	}
+}
+
+// broadcast sends method to every subscribed session that has finished
+// initializing. Plain HTTP clients have no channel to receive it and must
+// list again themselves.
+func (s *MCPServer) broadcast(method string) {
+	s.mu.RLock()
+	targets := make([]notifier, 0, len(s.subscribers))
+	for sess, notify := range s.subscribers {
+		if sess.state == stateReady {
+			targets = append(targets, notify)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(targets) > 0 {
+		log.Printf("Sending %s to %d clients", method, len(targets))
+	}
+	for _, notify := range targets {
+		notify(JSONRPCNotification{JsonRPC: "2.0", Method: method})
+	}
+}
+
+// UnregisterTool removes a tool at runtime. It reports whether the tool was
+// registered.
+func (s *MCPServer) UnregisterTool(name string) bool {
+	s.mu.Lock()
+	if _, ok := s.tools[name]; !ok {
+		s.mu.Unlock()
+		return false
+	}
+	delete(s.tools, name)
+	for i, n := range s.toolOrder {
+		if n == name {
+			s.toolOrder = append(s.toolOrder[:i:i], s.toolOrder[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	s.broadcast("notifications/tools/list_changed")
+	return true
+}
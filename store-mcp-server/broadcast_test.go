@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestToolListChanged(t *testing.T) {
+	echo := func(context.Context, map[string]interface{}) (CallToolResult, error) {
+		return CallToolResult{}, nil
+	}
+	tests := []struct {
+		name   string
+		ready  bool // the client sent notifications/initialized
+		leave  bool // the connection closed before the change
+		change func(t *testing.T, s *MCPServer)
+		want   int
+	}{
+		{"register after init", true, false, func(t *testing.T, s *MCPServer) {
+			if err := s.RegisterTool(Tool{Name: "late", InputSchema: InputSchema{Type: "object"}}, echo); err != nil {
+				t.Fatal(err)
+			}
+		}, 1},
+		{"unregister", true, false, func(t *testing.T, s *MCPServer) { s.UnregisterTool("first") }, 1},
+		{"unregister unknown", true, false, func(t *testing.T, s *MCPServer) { s.UnregisterTool("nope") }, 0},
+		{"handshake unfinished", false, false, func(t *testing.T, s *MCPServer) { s.UnregisterTool("first") }, 0},
+		{"connection closed", true, true, func(t *testing.T, s *MCPServer) { s.UnregisterTool("first") }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			if err := s.RegisterTool(Tool{Name: "first", InputSchema: InputSchema{Type: "object"}}, echo); err != nil {
+				t.Fatal(err)
+			}
+
+			sess := newSession()
+			ctx := withSession(context.Background(), sess)
+			call(t, s, ctx, "initialize", map[string]interface{}{"protocolVersion": supportedVersions[0]})
+			if tt.ready {
+				s.handleInitializedNotification(sess)
+			}
+			var got []string
+			unsubscribe := s.subscribe(sess, func(n JSONRPCNotification) { got = append(got, n.Method) })
+			if tt.leave {
+				unsubscribe()
+			}
+
+			tt.change(t, s)
+			if len(got) != tt.want {
+				t.Fatalf("got notifications %v, want %d", got, tt.want)
+			}
+			for _, m := range got {
+				if m != "notifications/tools/list_changed" {
+					t.Errorf("notification %q, want notifications/tools/list_changed", m)
+				}
+			}
+		})
+	}
+}
+
+func TestToolsCapabilityListChanged(t *testing.T) {
+	s := NewMCPServer()
+	init, ok := call(t, s, withSession(context.Background(), newSession()), "initialize",
+		map[string]interface{}{"protocolVersion": supportedVersions[0]}).Result.(InitializeResult)
+	if !ok {
+		t.Fatal("initialize returned no InitializeResult")
+	}
+	if init.Capabilities.Tools == nil || !init.Capabilities.Tools.ListChanged {
+		t.Errorf("tools capability = %+v, want listChanged", init.Capabilities.Tools)
+	}
+}
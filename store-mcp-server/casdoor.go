@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+//
+// --------------------
+// Casdoor endpoints
+// --------------------
+//
+
+// CasdoorEndpoints holds every OAuth/OIDC endpoint the server advertises or
+// talks to. They are all derived from a single Casdoor base URL.
+type CasdoorEndpoints struct {
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserinfoEndpoint      string
+	IntrospectionEndpoint string
+	JWKSURI               string
+	Scopes                []string
+}
+
+// newCasdoorEndpoints validates base as an absolute https URL and derives the
+// standard Casdoor endpoint paths from it.
+func newCasdoorEndpoints(base string, scopes []string) (*CasdoorEndpoints, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Casdoor URL %q: %w", base, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Casdoor URL %q: must be an absolute https URL", base)
+	}
+
+	base = strings.TrimRight(u.String(), "/")
+	return &CasdoorEndpoints{
+		Issuer:                base,
+		AuthorizationEndpoint: base + "/login/oauth/authorize",
+		TokenEndpoint:         base + "/api/login/oauth/access_token",
+		UserinfoEndpoint:      base + "/api/userinfo",
+		IntrospectionEndpoint: base + "/api/login/oauth/introspect",
+		JWKSURI:               base + "/.well-known/jwks",
+		Scopes:                scopes,
+	}, nil
+}
+
+// casdoorEndpoints resolves the endpoints from configuration. It returns nil
+// without error when no Casdoor settings are present at all.
+func (c *Config) casdoorEndpoints() (*CasdoorEndpoints, error) {
+	scopes := strings.Fields(c.Scopes)
+	if c.CasdoorURL != "" {
+		return newCasdoorEndpoints(c.CasdoorURL, scopes)
+	}
+	return legacyCasdoorEndpoints(scopes), nil
+}
+
+// legacyCasdoorEndpoints reads the individual OAUTH_* variables used before
+// CASDOOR_ENDPOINT existed. It returns nil if any required one is missing.
+func legacyCasdoorEndpoints(scopes []string) *CasdoorEndpoints {
+	e := &CasdoorEndpoints{
+		Issuer:                os.Getenv("OAUTH_ISSUER"),
+		AuthorizationEndpoint: os.Getenv("OAUTH_AUTHORIZATION_ENDPOINT"),
+		TokenEndpoint:         os.Getenv("OAUTH_TOKEN_ENDPOINT"),
+		JWKSURI:               os.Getenv("OAUTH_JWKS_URI"),
+		Scopes:                scopes,
+	}
+	if e.Issuer == "" || e.AuthorizationEndpoint == "" || e.TokenEndpoint == "" || e.JWKSURI == "" {
+		return nil
+	}
+	return e
+}
+
+// tokenValidator builds the validator selected by -token-validation. Calls
+// to Casdoor go through client.
+func (c *Config) tokenValidator(endpoints *CasdoorEndpoints, client *http.Client) (TokenValidator, error) {
+	if endpoints == nil {
+		return nil, errors.New("-require-auth needs a Casdoor endpoint")
+	}
+
+	switch c.TokenValidation {
+	case "jwt":
+		if c.Audience == "" {
+			return nil, errors.New("-require-auth needs -audience")
+		}
+		return NewJWTValidator(NewJWKSCache(endpoints.JWKSURI, c.JWKSTTL, client), endpoints.Issuer, c.Audience), nil
+	case "introspect":
+		if endpoints.IntrospectionEndpoint == "" {
+			return nil, errors.New("-token-validation=introspect needs -casdoor-url")
+		}
+		if c.ClientID == "" || c.ClientSecret == "" {
+			return nil, errors.New("-token-validation=introspect needs -client-id and -client-secret")
+		}
+		return NewIntrospector(endpoints.IntrospectionEndpoint, c.ClientID, c.ClientSecret, c.Audience, c.IntrospectionCacheTTL, client), nil
+	default:
+		return nil, fmt.Errorf("unknown token validation %q (want jwt or introspect)", c.TokenValidation)
+	}
+}
+
+// casdoorClient returns the HTTP client to share across all calls to
+// Casdoor. It keeps a small pool of connections to Casdoor alive, retries
+// transient failures per the configured policy, and bounds each call,
+// retries included, by -casdoor-timeout so a hung Casdoor can't pile up
+// goroutines. check_store_status reuses it with its own shorter deadline.
+func (c *Config) casdoorClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 20
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+
+	return &http.Client{
+		Timeout: c.CasdoorTimeout,
+		Transport: &retryTransport{
+			base: transport,
+			policy: RetryPolicy{
+				MaxAttempts:    c.CasdoorMaxAttempts,
+				BaseDelay:      c.CasdoorRetryBackoff,
+				MaxDelay:       5 * time.Second,
+				AttemptTimeout: 5 * time.Second,
+			},
+		},
+	}
+}
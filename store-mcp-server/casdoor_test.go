@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// A Casdoor that accepts connections but never answers must not hang the
+// caller past -casdoor-timeout.
+func TestCasdoorClientTimesOut(t *testing.T) {
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hang)
+
+	tests := []struct {
+		name     string
+		attempts int
+	}{
+		{"single attempt", 1},
+		{"with retries", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const timeout = 100 * time.Millisecond
+			cfg := &Config{CasdoorTimeout: timeout, CasdoorMaxAttempts: tt.attempts, CasdoorRetryBackoff: time.Millisecond}
+			start := time.Now()
+			resp, err := cfg.casdoorClient().Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatal("request to a hung server succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > 10*timeout {
+				t.Errorf("gave up after %s, want near %s", elapsed, timeout)
+			}
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				t.Errorf("err = %v, want a timeout", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+//
+// --------------------
+// Store catalog
+// --------------------
+//
+
+// Store is the stable shape returned to clients for each store.
+type Store struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Category    string `json:"category"`
+	Description string `json:"description,omitempty"`
+	// SearchURL opens the store's search results, with {query} marking
+	// where the search text goes.
+	SearchURL string `json:"searchUrl,omitempty"`
+}
+
+// queryPlaceholder marks the search text in Store.SearchURL.
+const queryPlaceholder = "{query}"
+
+// SearchLink returns the store's search URL for query, escaped for its place
+// in the URL. ok is false when the store has no search URL.
+func (s Store) SearchLink(query string) (link string, ok bool) {
+	if s.SearchURL == "" {
+		return "", false
+	}
+	i := strings.Index(s.SearchURL, queryPlaceholder)
+	escaped := url.PathEscape(query)
+	if q := strings.IndexByte(s.SearchURL, '?'); q >= 0 && q < i {
+		escaped = url.QueryEscape(query)
+	}
+	return s.SearchURL[:i] + escaped + s.SearchURL[i+len(queryPlaceholder):], true
+}
+
+// CategoryCount is how many stores the catalog has in one category.
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+//go:embed catalog.json
+var defaultCatalogJSON []byte
+
+// StoreCatalog is the read-only set of stores the tools query.
+type StoreCatalog struct {
+	stores []Store
+}
+
+// ParseStoreCatalog decodes a JSON array of stores. Every store must have a
+// name and an absolute URL.
+func ParseStoreCatalog(data []byte) (*StoreCatalog, error) {
+	return parseStoreCatalog(data, false)
+}
+
+// parseStoreCatalog is ParseStoreCatalog, optionally expanding ${VAR} and
+// $VAR references in every string field from the environment first.
+func parseStoreCatalog(data []byte, expandEnv bool) (*StoreCatalog, error) {
+	var stores []Store
+	if err := json.Unmarshal(data, &stores); err != nil {
+		return nil, fmt.Errorf("parse catalog: %w", err)
+	}
+
+	for i := range stores {
+		if expandEnv {
+			stores[i].expandEnv()
+		}
+		store := stores[i]
+		if strings.TrimSpace(store.Name) == "" {
+			return nil, fmt.Errorf("catalog entry %d: name is required", i)
+		}
+		u, err := url.Parse(store.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("catalog entry %d (%s): invalid url %q", i, store.Name, store.URL)
+		}
+		if store.SearchURL != "" {
+			u, err := url.Parse(strings.Replace(store.SearchURL, queryPlaceholder, "q", 1))
+			if err != nil || u.Scheme == "" || u.Host == "" || strings.Count(store.SearchURL, queryPlaceholder) != 1 {
+				return nil, fmt.Errorf("catalog entry %d (%s): invalid searchUrl %q: want an absolute URL with one %s", i, store.Name, store.SearchURL, queryPlaceholder)
+			}
+		}
+	}
+
+	return &StoreCatalog{stores: stores}, nil
+}
+
+// expandEnv substitutes environment variables into the store's fields.
+// Undefined variables expand to the empty string.
+func (s *Store) expandEnv() {
+	s.Name = os.ExpandEnv(s.Name)
+	s.URL = os.ExpandEnv(s.URL)
+	s.Category = os.ExpandEnv(s.Category)
+	s.Description = os.ExpandEnv(s.Description)
+	s.SearchURL = os.ExpandEnv(s.SearchURL)
+}
+
+// LoadStoreCatalogFile reads a catalog from a JSON file on disk. With
+// expandEnv, environment variables in its fields are expanded as by
+// parseStoreCatalog.
+func LoadStoreCatalogFile(path string, expandEnv bool) (*StoreCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read catalog: %w", err)
+	}
+	return parseStoreCatalog(data, expandEnv)
+}
+
+// loadCatalog returns the catalog at path, or the default one when path is
+// empty. If the file can't be loaded, fallback decides between returning
+// the error and logging it and serving the default catalog instead.
+func loadCatalog(path string, expandEnv, fallback bool) (*StoreCatalog, error) {
+	if path == "" {
+		return DefaultStoreCatalog(), nil
+	}
+	catalog, err := LoadStoreCatalogFile(path, expandEnv)
+	if err != nil {
+		if !fallback {
+			return nil, err
+		}
+		slog.Error("Catalog failed to load; serving the built-in catalog", "path", path, "error", err)
+		return DefaultStoreCatalog(), nil
+	}
+	log.Printf("Loaded %d stores from %s", catalog.Len(), path)
+	return catalog, nil
+}
+
+// DefaultStoreCatalog returns the catalog compiled into the binary.
+func DefaultStoreCatalog() *StoreCatalog {
+	c, err := ParseStoreCatalog(defaultCatalogJSON)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Len returns the number of stores in the catalog.
+func (c *StoreCatalog) Len() int {
+	return len(c.stores)
+}
+
+// All returns every store in catalog order.
+func (c *StoreCatalog) All() []Store {
+	return append([]Store(nil), c.stores...)
+}
+
+// Categories returns the distinct store categories in catalog order.
+func (c *StoreCatalog) Categories() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, store := range c.stores {
+		if store.Category != "" && !seen[store.Category] {
+			seen[store.Category] = true
+			categories = append(categories, store.Category)
+		}
+	}
+	return categories
+}
+
+// CategoryCounts returns each distinct category with its number of stores,
+// largest first. Ties keep catalog order.
+func (c *StoreCatalog) CategoryCounts() []CategoryCount {
+	index := make(map[string]int)
+	counts := []CategoryCount{}
+	for _, store := range c.stores {
+		if store.Category == "" {
+			continue
+		}
+		i, ok := index[store.Category]
+		if !ok {
+			i = len(counts)
+			index[store.Category] = i
+			counts = append(counts, CategoryCount{Category: store.Category})
+		}
+		counts[i].Count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Count > counts[j].Count
+	})
+	return counts
+}
+
+// Find looks a store up by name, ignoring case.
+func (c *StoreCatalog) Find(name string) (Store, bool) {
+	for _, store := range c.stores {
+		if strings.EqualFold(store.Name, name) {
+			return store, true
+		}
+	}
+	return Store{}, false
+}
+
+// Search returns stores whose name or category contains query, ignoring
+// case. A non-empty category further restricts results to that category.
+func (c *StoreCatalog) Search(query, category string) []Store {
+	query = strings.ToLower(query)
+
+	matches := []Store{}
+	for _, store := range c.stores {
+		if category != "" && !strings.EqualFold(store.Category, category) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(store.Name), query) ||
+			strings.Contains(strings.ToLower(store.Category), query) {
+			matches = append(matches, store)
+		}
+	}
+	return matches
+}
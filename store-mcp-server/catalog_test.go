@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to name in a new temporary directory and returns
+// its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCatalogExpandEnv(t *testing.T) {
+	t.Setenv("STORE_REGION", "in")
+	const catalog = `[{"name": "Shop ${STORE_REGION}", "url": "https://shop.example.com/${STORE_REGION}", "searchUrl": "https://shop.example.com/s?q={query}&r=$STORE_REGION${UNSET_STORE_VAR}", "category": "marketplace"}]`
+	path := writeFile(t, "catalog.json", catalog)
+
+	tests := []struct {
+		name       string
+		expand     bool
+		wantName   string
+		wantURL    string
+		wantSearch string
+	}{
+		{"expanded", true, "Shop in", "https://shop.example.com/in", "https://shop.example.com/s?q={query}&r=in"},
+		{"left alone", false, "Shop ${STORE_REGION}", "https://shop.example.com/${STORE_REGION}", "https://shop.example.com/s?q={query}&r=$STORE_REGION${UNSET_STORE_VAR}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := LoadStoreCatalogFile(path, tt.expand)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := c.All()[0]
+			if store.Name != tt.wantName || store.URL != tt.wantURL || store.SearchURL != tt.wantSearch {
+				t.Errorf("store = %+v, want name %q url %q searchUrl %q", store, tt.wantName, tt.wantURL, tt.wantSearch)
+			}
+		})
+	}
+}
+
+// URLs are validated after expansion.
+func TestCatalogExpandEnvValidatesResult(t *testing.T) {
+	t.Setenv("STORE_HOST", "shop.example.com")
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://${STORE_HOST}", false},
+		{"${UNSET_STORE_URL}", true},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, "catalog.json", `[{"name": "Shop", "url": "`+tt.url+`", "category": "marketplace"}]`)
+		if _, err := LoadStoreCatalogFile(path, true); (err != nil) != tt.wantErr {
+			t.Errorf("url %s: err = %v, want error = %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCatalogPathExpanded(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CATALOG_DIR", dir)
+	cfg, err := parseConfig([]string{"-catalog", "${CATALOG_DIR}/stores.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "stores.json"); cfg.CatalogPath != want {
+		t.Errorf("CatalogPath = %q, want %q", cfg.CatalogPath, want)
+	}
+}
+
+func TestCategoryCounts(t *testing.T) {
+	store := func(name, category string) string {
+		return `{"name": "` + name + `", "url": "https://` + name + `.example.com", "category": "` + category + `"}`
+	}
+	tests := []struct {
+		name    string
+		catalog string
+		want    string
+		summary string
+	}{
+		{
+			"several categories",
+			"[" + store("a", "fashion") + "," + store("b", "marketplace") + "," + store("c", "marketplace") + "," +
+				store("d", "grocery") + "," + store("e", "marketplace") + "," + store("f", "grocery") + "]",
+			`[{"category":"marketplace","count":3},{"category":"grocery","count":2},{"category":"fashion","count":1}]`,
+			"3 categories: marketplace (3), grocery (2), fashion (1)",
+		},
+		{
+			"ties keep catalog order",
+			"[" + store("a", "books") + "," + store("b", "toys") + "]",
+			`[{"category":"books","count":1},{"category":"toys","count":1}]`,
+			"2 categories: books (1), toys (1)",
+		},
+		{"uncategorized skipped", "[" + store("a", "") + "]", `[]`, "No categories."},
+		{"empty catalog", "[]", `[]`, "No categories."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseStoreCatalog([]byte(tt.catalog))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := c.categoriesTool(context.Background(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.IsError || len(result.Content) != 2 {
+				t.Fatalf("result = %+v, want the JSON and a summary", result)
+			}
+			if got := result.Content[0].Text; got != tt.want {
+				t.Errorf("counts = %s, want %s", got, tt.want)
+			}
+			if got := result.Content[1].Text; got != tt.summary {
+				t.Errorf("summary = %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
+
+func TestSearchLink(t *testing.T) {
+	tests := []struct {
+		name      string
+		searchURL string
+		query     string
+		want      string
+		wantOK    bool
+	}{
+		{"query parameter", "https://shop.example.com/search?q={query}", "running shoes & socks", "https://shop.example.com/search?q=running+shoes+%26+socks", true},
+		{"path segment", "https://shop.example.com/s/{query}/all", "a/b c", "https://shop.example.com/s/a%2Fb%20c/all", true},
+		{"non-ascii", "https://shop.example.com/search?q={query}", "कुर्ता", "https://shop.example.com/search?q=%E0%A4%95%E0%A5%81%E0%A4%B0%E0%A5%8D%E0%A4%A4%E0%A4%BE", true},
+		{"no template", "", "shoes", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Store{SearchURL: tt.searchURL}.SearchLink(tt.query)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("SearchLink(%q) = %q, %v; want %q, %v", tt.query, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoadCatalogFallback(t *testing.T) {
+	good := writeFile(t, "stores.json", `[{"name": "Nykaa", "url": "https://www.nykaa.com", "category": "beauty"}]`)
+	broken := writeFile(t, "broken.json", `[{"name": "Nykaa",`)
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	defaultLen := DefaultStoreCatalog().Len()
+
+	tests := []struct {
+		name     string
+		path     string
+		fallback bool
+		wantErr  bool
+		wantLen  int
+		wantLog  bool
+	}{
+		{"no path", "", false, false, defaultLen, false},
+		{"good file", good, false, false, 1, false},
+		{"good file with fallback", good, true, false, 1, false},
+		{"broken file fails fast", broken, false, true, 0, false},
+		{"missing file fails fast", missing, false, true, 0, false},
+		{"broken file falls back", broken, true, false, defaultLen, true},
+		{"missing file falls back", missing, true, false, defaultLen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			prev := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+			t.Cleanup(func() { slog.SetDefault(prev) })
+
+			c, err := loadCatalog(tt.path, false, tt.fallback)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && c.Len() != tt.wantLen {
+				t.Errorf("loaded %d stores, want %d", c.Len(), tt.wantLen)
+			}
+			if got := strings.Contains(logged.String(), "serving the built-in catalog"); got != tt.wantLog {
+				t.Errorf("fallback logged = %v, want %v: %s", got, tt.wantLog, logged.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//
+// --------------------
+// Argument completion
+// --------------------
+//
+
+// maxCompletions is the most values a completion/complete result may carry.
+const maxCompletions = 100
+
+type CompletionsCapability struct{}
+
+// CompleteRef names what is being completed: a prompt ("ref/prompt", Name)
+// or a resource template ("ref/resource", URI).
+type CompleteRef struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+type CompleteArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CompleteParams struct {
+	Ref      CompleteRef      `json:"ref"`
+	Argument CompleteArgument `json:"argument"`
+}
+
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+type CompleteResult struct {
+	Completion Completion `json:"completion"`
+}
+
+// CompletionProvider suggests values for a prompt or resource-template
+// argument given what the user has typed so far.
+type CompletionProvider interface {
+	Complete(ref CompleteRef, argument, value string) ([]string, error)
+}
+
+// RegisterCompletions enables the completions capability.
+func (s *MCPServer) RegisterCompletions(p CompletionProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completions = p
+}
+
+func (s *MCPServer) completionProvider() CompletionProvider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.completions
+}
+
+func (s *MCPServer) handleComplete(id interface{}, params json.RawMessage) JSONRPCResponse {
+	p := s.completionProvider()
+	if p == nil {
+		return s.sendError(id, -32601, "Method not found", MethodErrorData{Method: "completion/complete"})
+	}
+
+	var req CompleteParams
+	if err := json.Unmarshal(params, &req); err != nil {
+		return newInvalidParams(id, err)
+	}
+
+	values, err := p.Complete(req.Ref, req.Argument.Name, req.Argument.Value)
+	if err != nil {
+		return newInvalidParams(id, err)
+	}
+
+	completion := Completion{Values: values, Total: len(values)}
+	if completion.Values == nil {
+		completion.Values = []string{}
+	}
+	if len(values) > maxCompletions {
+		completion.Values = values[:maxCompletions]
+		completion.HasMore = true
+	}
+
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  CompleteResult{Completion: completion},
+	}
+}
+
+// Complete suggests store names for store resources and categories for the
+// recommend_store prompt, matching the typed prefix case-insensitively.
+func (c *StoreCatalog) Complete(ref CompleteRef, argument, value string) ([]string, error) {
+	var candidates []string
+	switch {
+	case ref.Type == "ref/resource" && ref.URI == storeURITemplate && argument == "name":
+		for _, store := range c.stores {
+			candidates = append(candidates, store.Name)
+		}
+	case ref.Type == "ref/prompt" && ref.Name == "recommend_store" && argument == "product_category":
+		candidates = c.Categories()
+	case ref.Type == "ref/resource", ref.Type == "ref/prompt":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown ref type %q", ref.Type)
+	}
+
+	prefix := strings.ToLower(value)
+	var matches []string
+	for _, v := range candidates {
+		if strings.HasPrefix(strings.ToLower(v), prefix) {
+			matches = append(matches, v)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCatalogComplete(t *testing.T) {
+	catalog := DefaultStoreCatalog()
+	storeRef := CompleteRef{Type: "ref/resource", URI: storeURITemplate}
+
+	tests := []struct {
+		name     string
+		ref      CompleteRef
+		argument string
+		value    string
+		want     []string
+		wantErr  bool
+	}{
+		{"prefix", storeRef, "name", "Fl", []string{"Flipkart"}, false},
+		{"case-insensitive", storeRef, "name", "ta", []string{"Tata CLiQ"}, false},
+		{"several matches", storeRef, "name", "", []string{"Flipkart", "Amazon India", "Reliance Digital", "Myntra", "Snapdeal", "Tata CLiQ"}, false},
+		{"no match", storeRef, "name", "zz", nil, false},
+		{"other argument", storeRef, "category", "F", nil, false},
+		{"other template", CompleteRef{Type: "ref/resource", URI: "shop://{name}"}, "name", "F", nil, false},
+		{"unknown ref type", CompleteRef{Type: "ref/tool"}, "name", "F", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := catalog.Complete(tt.ref, tt.argument, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Complete(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// The completed template must be one clients can discover.
+func TestStoreTemplateAdvertised(t *testing.T) {
+	s := NewMCPServer()
+	s.RegisterResources(DefaultStoreCatalog())
+	resp := call(t, s, initialized(t, s), "resources/templates/list", nil)
+	if resp.Error != nil {
+		t.Fatalf("resources/templates/list: %+v", resp.Error)
+	}
+	templates := resp.Result.(ResourceTemplatesListResult).ResourceTemplates
+	if len(templates) != 1 || templates[0].URITemplate != storeURITemplate {
+		t.Errorf("templates = %+v, want %s", templates, storeURITemplate)
+	}
+}
+
+type manyCompletions int
+
+func (n manyCompletions) Complete(CompleteRef, string, string) ([]string, error) {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = fmt.Sprint(i)
+	}
+	return values, nil
+}
+
+func TestCompleteCapsValues(t *testing.T) {
+	tests := []struct {
+		available   int
+		wantValues  int
+		wantHasMore bool
+	}{
+		{0, 0, false},
+		{maxCompletions, maxCompletions, false},
+		{maxCompletions + 1, maxCompletions, true},
+	}
+	for _, tt := range tests {
+		s := NewMCPServer()
+		s.RegisterCompletions(manyCompletions(tt.available))
+		resp := call(t, s, initialized(t, s), "completion/complete", map[string]interface{}{
+			"ref":      map[string]string{"type": "ref/resource", "uri": storeURITemplate},
+			"argument": map[string]string{"name": "name", "value": ""},
+		})
+		if resp.Error != nil {
+			t.Fatalf("completion/complete: %+v", resp.Error)
+		}
+		got := resp.Result.(CompleteResult).Completion
+		if len(got.Values) != tt.wantValues || got.HasMore != tt.wantHasMore || got.Total != tt.available {
+			t.Errorf("%d available: %d values, hasMore %v, total %d", tt.available, len(got.Values), got.HasMore, got.Total)
+		}
+	}
+}
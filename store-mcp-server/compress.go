@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+//
+// --------------------
+// Response compression
+// --------------------
+//
+
+// gzipMinBytes is the smallest body worth compressing; below it gzip's
+// framing costs more than it saves.
+const gzipMinBytes = 1024
+
+// gzipResponses compresses bodies of at least gzipMinBytes for clients that
+// accept gzip. It buffers up to that size to decide, so it must not wrap
+// streaming handlers.
+func gzipResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.TrimSpace(params) != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter holds back the status and body until it knows whether the body
+// reaches gzipMinBytes.
+type gzipWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+}
+
+func (g *gzipWriter) WriteHeader(code int) {
+	if !g.wroteHeader {
+		g.status = code
+		g.wroteHeader = true
+	}
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	g.buf.Write(p)
+	if g.buf.Len() < gzipMinBytes {
+		return len(p), nil
+	}
+
+	h := g.ResponseWriter.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	if _, err := g.gz.Write(g.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	g.buf.Reset()
+	return len(p), nil
+}
+
+// Close flushes whatever was held back, compressed or not.
+func (g *gzipWriter) Close() error {
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	_, err := g.ResponseWriter.Write(g.buf.Bytes())
+	return err
+}
+
+func (g *gzipWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipResponses(t *testing.T) {
+	large := `{"items":"` + strings.Repeat("x", 2*gzipMinBytes) + `"}`
+	small := `{"ok":true}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		status         int
+		compressed     bool
+	}{
+		{"large and accepted", "gzip, deflate", large, http.StatusOK, true},
+		{"keeps status", "gzip", large, http.StatusBadRequest, true},
+		{"small body", "gzip", small, http.StatusOK, false},
+		{"not accepted", "", large, http.StatusOK, false},
+		{"refused with q=0", "gzip;q=0", large, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				// Several writes, so the threshold is crossed part way
+				for i := 0; i < len(tt.body); i += 100 {
+					io.WriteString(w, tt.body[i:min(i+100, len(tt.body))])
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			compressed := rec.Header().Get("Content-Encoding") == "gzip"
+			if compressed != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.compressed)
+			}
+
+			body := rec.Body.Bytes()
+			if compressed {
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != tt.body {
+				t.Errorf("body round trip changed it: got %d bytes, want %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+//
+// --------------------
+// Configuration
+// --------------------
+//
+
+type Config struct {
+	ServerName  string
+	Transport   string
+	Addr        string
+	TLSCert     string
+	TLSKey      string
+	CasdoorURL  string
+	Scopes      string
+	RequireAuth bool
+	AdminScope  string
+	Audience    string
+	JWKSTTL     time.Duration
+
+	TokenValidation       string
+	ClientID              string
+	ClientSecret          string
+	IntrospectionCacheTTL time.Duration
+
+	ResourceURL      string
+	CatalogPath      string
+	CatalogExpandEnv bool
+	CatalogFallback  bool
+
+	ShutdownTimeout time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	MaxBodyBytes    int64
+	MaxConcurrency  int
+
+	CORSOrigins string
+	CORSMethods string
+	CORSHeaders string
+	CORSMaxAge  int
+
+	AllowedOrigins string
+
+	LogFormat string
+	Metrics   bool
+	Debug     bool
+
+	OTLPEndpoint string
+
+	PingInterval time.Duration
+	SessionTTL   time.Duration
+
+	RateLimit float64
+	RateBurst int
+
+	CasdoorTimeout      time.Duration
+	CasdoorMaxAttempts  int
+	CasdoorRetryBackoff time.Duration
+
+	StoreCheckTimeout time.Duration
+	ToolTimeout       time.Duration
+	ToolCacheTTL      time.Duration
+	DedupTTL          time.Duration
+	SlowToolMS        int
+	SanitizeOutput    string
+
+	EnabledTools  string
+	DisabledTools string
+}
+
+// parseConfig reads command-line flags, falling back to environment
+// variables and then to built-in defaults.
+func parseConfig(args []string) (*Config, error) {
+	cfg := &Config{}
+	env := &envDefaults{}
+
+	fs := flag.NewFlagSet("mcp-server", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("MCP_CONFIG"), "YAML or JSON file of flag values, keyed by flag name; flags and environment variables take precedence (env MCP_CONFIG)")
+	fs.StringVar(&cfg.ServerName, "server-name", envOr("MCP_SERVER_NAME", defaultServerName), "name reported to clients in serverInfo (env MCP_SERVER_NAME)")
+	fs.StringVar(&cfg.Transport, "transport", "http", "transport to serve MCP over: http or stdio")
+	fs.StringVar(&cfg.Addr, "addr", envOr("MCP_LISTEN_ADDR", ":8080"), "HTTP listen address (env MCP_LISTEN_ADDR)")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", os.Getenv("MCP_TLS_CERT"), "PEM certificate file; with -tls-key, serve HTTPS instead of HTTP (env MCP_TLS_CERT)")
+	fs.StringVar(&cfg.TLSKey, "tls-key", os.Getenv("MCP_TLS_KEY"), "PEM private key file for -tls-cert (env MCP_TLS_KEY)")
+	fs.StringVar(&cfg.CasdoorURL, "casdoor-url", os.Getenv("CASDOOR_ENDPOINT"), "Casdoor base URL, e.g. https://casdoor.example.com (env CASDOOR_ENDPOINT)")
+	fs.StringVar(&cfg.Scopes, "scopes", envOr("OAUTH_SCOPES", "openid profile email"), "space-separated OAuth scopes to advertise (env OAUTH_SCOPES)")
+	fs.BoolVar(&cfg.RequireAuth, "require-auth", env.bool("MCP_REQUIRE_AUTH", false), "require a valid Casdoor bearer token on /mcp (env MCP_REQUIRE_AUTH)")
+	fs.StringVar(&cfg.AdminScope, "admin-scope", envOr("MCP_ADMIN_SCOPE", defaultAdminScope), "token scope required to call admin/shutdown; the method only exists with -require-auth (env MCP_ADMIN_SCOPE)")
+	fs.StringVar(&cfg.Audience, "audience", os.Getenv("OAUTH_AUDIENCE"), "expected token audience, usually the Casdoor client ID (env OAUTH_AUDIENCE)")
+	fs.StringVar(&cfg.ResourceURL, "resource-url", os.Getenv("MCP_RESOURCE_URL"), "public URL of /mcp advertised as the protected resource; derived from the request if empty (env MCP_RESOURCE_URL)")
+	fs.DurationVar(&cfg.JWKSTTL, "jwks-ttl", env.duration("JWKS_CACHE_TTL", time.Hour), "how long to cache Casdoor signing keys (env JWKS_CACHE_TTL)")
+	fs.StringVar(&cfg.TokenValidation, "token-validation", envOr("MCP_TOKEN_VALIDATION", "jwt"), "how to validate bearer tokens: jwt (local, via JWKS) or introspect (env MCP_TOKEN_VALIDATION)")
+	fs.StringVar(&cfg.ClientID, "client-id", os.Getenv("OAUTH_CLIENT_ID"), "Casdoor client ID used for token introspection (env OAUTH_CLIENT_ID)")
+	fs.StringVar(&cfg.ClientSecret, "client-secret", os.Getenv("OAUTH_CLIENT_SECRET"), "Casdoor client secret used for token introspection (env OAUTH_CLIENT_SECRET)")
+	fs.DurationVar(&cfg.IntrospectionCacheTTL, "introspection-cache-ttl", env.duration("MCP_INTROSPECTION_CACHE_TTL", 30*time.Second), "how long to cache active introspection results (env MCP_INTROSPECTION_CACHE_TTL)")
+	fs.StringVar(&cfg.CatalogPath, "catalog", os.Getenv("MCP_CATALOG"), "path to a JSON store catalog overriding the built-in one; ${VAR} references are expanded (env MCP_CATALOG)")
+	fs.BoolVar(&cfg.CatalogExpandEnv, "catalog-expand-env", env.bool("MCP_CATALOG_EXPAND_ENV", false), "expand ${VAR} references in -catalog fields from the environment; undefined variables become empty (env MCP_CATALOG_EXPAND_ENV)")
+	fs.BoolVar(&cfg.CatalogFallback, "catalog-fallback", env.bool("MCP_CATALOG_FALLBACK", false), "serve the built-in catalog if -catalog can't be loaded, instead of exiting (env MCP_CATALOG_FALLBACK)")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", env.duration("MCP_SHUTDOWN_TIMEOUT", 10*time.Second), "grace period for in-flight requests on shutdown (env MCP_SHUTDOWN_TIMEOUT)")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", env.duration("MCP_READ_TIMEOUT", 15*time.Second), "maximum time to read a request, including the body (env MCP_READ_TIMEOUT)")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", env.duration("MCP_WRITE_TIMEOUT", 30*time.Second), "maximum time to write a response (env MCP_WRITE_TIMEOUT)")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", env.duration("MCP_IDLE_TIMEOUT", 60*time.Second), "how long keep-alive connections may sit idle (env MCP_IDLE_TIMEOUT)")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", env.int64("MCP_MAX_BODY_BYTES", 1<<20), "maximum size of a /mcp request body (env MCP_MAX_BODY_BYTES)")
+	fs.IntVar(&cfg.MaxConcurrency, "max-concurrency", int(env.int64("MCP_MAX_CONCURRENCY", 256)), "maximum /mcp requests handled at once; more get 503 with Retry-After; 0 disables (env MCP_MAX_CONCURRENCY)")
+	fs.StringVar(&cfg.CORSOrigins, "cors-origins", os.Getenv("MCP_CORS_ORIGINS"), "comma-separated browser origins allowed to call the server; empty allows any (env MCP_CORS_ORIGINS)")
+	fs.StringVar(&cfg.CORSMethods, "cors-methods", envOr("MCP_CORS_METHODS", "GET, POST, DELETE, OPTIONS"), "value of Access-Control-Allow-Methods (env MCP_CORS_METHODS)")
+	fs.StringVar(&cfg.CORSHeaders, "cors-headers", envOr("MCP_CORS_HEADERS", "Content-Type, Authorization, Mcp-Session-Id"), "value of Access-Control-Allow-Headers (env MCP_CORS_HEADERS)")
+	fs.IntVar(&cfg.CORSMaxAge, "cors-max-age", int(env.int64("MCP_CORS_MAX_AGE", 600)), "seconds browsers may cache a CORS preflight; 0 omits Access-Control-Max-Age (env MCP_CORS_MAX_AGE)")
+	fs.StringVar(&cfg.AllowedOrigins, "allowed-origins", envOr("MCP_ALLOWED_ORIGINS", "http://localhost,https://localhost,http://127.0.0.1,https://127.0.0.1"), "comma-separated origins accepted when listening on localhost, and by /mcp/ws on any address; others get 403 (env MCP_ALLOWED_ORIGINS)")
+	fs.StringVar(&cfg.LogFormat, "log-format", envOr("MCP_LOG_FORMAT", "text"), "log output format: text or json (env MCP_LOG_FORMAT)")
+	fs.BoolVar(&cfg.Metrics, "metrics", env.bool("MCP_METRICS", false), "expose Prometheus metrics on /metrics (env MCP_METRICS)")
+	fs.BoolVar(&cfg.Debug, "debug", env.bool("MCP_DEBUG", false), "expose debugging endpoints such as /debug/audit (env MCP_DEBUG)")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector URL to export traces to, e.g. http://otel-collector:4318; empty disables tracing (env OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.DurationVar(&cfg.PingInterval, "ping-interval", env.duration("MCP_PING_INTERVAL", 0), "ping stdio clients after this much silence; 0 disables (env MCP_PING_INTERVAL)")
+
+	fs.DurationVar(&cfg.SessionTTL, "session-ttl", env.duration("MCP_SESSION_TTL", 30*time.Minute), "expire HTTP sessions idle for this long; 0 keeps them forever (env MCP_SESSION_TTL)")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", env.float("MCP_RATE_LIMIT", 0), "tools/call requests per second allowed per client; 0 disables (env MCP_RATE_LIMIT)")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", int(env.int64("MCP_RATE_BURST", 10)), "tools/call burst allowed per client above -rate-limit (env MCP_RATE_BURST)")
+	fs.DurationVar(&cfg.CasdoorTimeout, "casdoor-timeout", env.duration("CASDOOR_TIMEOUT", 10*time.Second), "overall time limit for one call to Casdoor, retries included (env CASDOOR_TIMEOUT)")
+	fs.IntVar(&cfg.CasdoorMaxAttempts, "casdoor-max-attempts", int(env.int64("CASDOOR_MAX_ATTEMPTS", 3)), "attempts per Casdoor call before giving up on transient errors (env CASDOOR_MAX_ATTEMPTS)")
+	fs.DurationVar(&cfg.CasdoorRetryBackoff, "casdoor-retry-backoff", env.duration("CASDOOR_RETRY_BACKOFF", 200*time.Millisecond), "initial delay between Casdoor retries, doubled each time (env CASDOOR_RETRY_BACKOFF)")
+	fs.DurationVar(&cfg.ToolTimeout, "tool-timeout", env.duration("MCP_TOOL_TIMEOUT", defaultToolTimeout), "maximum time one tool call may run before it is cancelled; 0 disables (env MCP_TOOL_TIMEOUT)")
+	fs.IntVar(&cfg.SlowToolMS, "slow-tool-ms", int(env.int64("MCP_SLOW_TOOL_MS", 1000)), "log a warning for tool calls taking longer than this many milliseconds; 0 disables (env MCP_SLOW_TOOL_MS)")
+	fs.StringVar(&cfg.SanitizeOutput, "sanitize-output", envOr("MCP_SANITIZE_OUTPUT", sanitizeStrip), "cleaning of tool result text: off, strip (remove control characters) or flag (also mark prompt-injection phrases in _meta) (env MCP_SANITIZE_OUTPUT)")
+	fs.DurationVar(&cfg.ToolCacheTTL, "tool-cache-ttl", env.duration("MCP_TOOL_CACHE_TTL", time.Minute), "how long to reuse results of tools whose output depends only on their arguments; 0 disables (env MCP_TOOL_CACHE_TTL)")
+	fs.DurationVar(&cfg.DedupTTL, "dedup-ttl", env.duration("MCP_DEDUP_TTL", 30*time.Second), "how long a session's retried request id gets the original reply instead of running again; 0 disables (env MCP_DEDUP_TTL)")
+	fs.StringVar(&cfg.EnabledTools, "enabled-tools", os.Getenv("MCP_ENABLED_TOOLS"), "comma-separated tools to expose; empty exposes all (env MCP_ENABLED_TOOLS)")
+	fs.StringVar(&cfg.DisabledTools, "disabled-tools", os.Getenv("MCP_DISABLED_TOOLS"), "comma-separated tools to hide, applied after -enabled-tools (env MCP_DISABLED_TOOLS)")
+	fs.DurationVar(&cfg.StoreCheckTimeout, "store-check-timeout", env.duration("MCP_STORE_CHECK_TIMEOUT", 5*time.Second), "time limit for check_store_status to reach a store's website (env MCP_STORE_CHECK_TIMEOUT)")
+
+	if env.err != nil {
+		fmt.Fprintln(fs.Output(), env.err)
+		return nil, env.err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *configPath != "" {
+		if err := applyConfigFile(fs, *configPath); err != nil {
+			fmt.Fprintln(fs.Output(), err)
+			return nil, err
+		}
+	}
+	cfg.CatalogPath = os.ExpandEnv(cfg.CatalogPath)
+	return cfg, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envDefaults reads flag defaults from the environment. A value that
+// doesn't parse is remembered so startup fails on it, as it would for the
+// same value given as a flag.
+type envDefaults struct {
+	err error
+}
+
+func (e *envDefaults) lookup(key string, parse func(string) error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	if err := parse(v); err != nil && e.err == nil {
+		e.err = fmt.Errorf("invalid value %q for environment variable %s: %w", v, key, err)
+	}
+}
+
+func (e *envDefaults) duration(key string, def time.Duration) time.Duration {
+	e.lookup(key, func(v string) error {
+		parsed, err := time.ParseDuration(v)
+		if err == nil {
+			def = parsed
+		}
+		return err
+	})
+	return def
+}
+
+func (e *envDefaults) int64(key string, def int64) int64 {
+	e.lookup(key, func(v string) error {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			def = parsed
+		}
+		return err
+	})
+	return def
+}
+
+func (e *envDefaults) float(key string, def float64) float64 {
+	e.lookup(key, func(v string) error {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			def = parsed
+		}
+		return err
+	})
+	return def
+}
+
+func (e *envDefaults) bool(key string, def bool) bool {
+	e.lookup(key, func(v string) error {
+		parsed, err := strconv.ParseBool(v)
+		if err == nil {
+			def = parsed
+		}
+		return err
+	})
+	return def
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//
+// --------------------
+// Config file
+// --------------------
+//
+
+// flagEnv names the environment variable each flag falls back to. A config
+// file value is ignored while that variable is set.
+var flagEnv = map[string]string{
+	"server-name":             "MCP_SERVER_NAME",
+	"addr":                    "MCP_LISTEN_ADDR",
+	"tls-cert":                "MCP_TLS_CERT",
+	"tls-key":                 "MCP_TLS_KEY",
+	"casdoor-url":             "CASDOOR_ENDPOINT",
+	"scopes":                  "OAUTH_SCOPES",
+	"require-auth":            "MCP_REQUIRE_AUTH",
+	"admin-scope":             "MCP_ADMIN_SCOPE",
+	"audience":                "OAUTH_AUDIENCE",
+	"resource-url":            "MCP_RESOURCE_URL",
+	"jwks-ttl":                "JWKS_CACHE_TTL",
+	"token-validation":        "MCP_TOKEN_VALIDATION",
+	"client-id":               "OAUTH_CLIENT_ID",
+	"client-secret":           "OAUTH_CLIENT_SECRET",
+	"introspection-cache-ttl": "MCP_INTROSPECTION_CACHE_TTL",
+	"catalog":                 "MCP_CATALOG",
+	"catalog-expand-env":      "MCP_CATALOG_EXPAND_ENV",
+	"catalog-fallback":        "MCP_CATALOG_FALLBACK",
+	"shutdown-timeout":        "MCP_SHUTDOWN_TIMEOUT",
+	"read-timeout":            "MCP_READ_TIMEOUT",
+	"write-timeout":           "MCP_WRITE_TIMEOUT",
+	"idle-timeout":            "MCP_IDLE_TIMEOUT",
+	"max-body-bytes":          "MCP_MAX_BODY_BYTES",
+	"max-concurrency":         "MCP_MAX_CONCURRENCY",
+	"cors-origins":            "MCP_CORS_ORIGINS",
+	"cors-methods":            "MCP_CORS_METHODS",
+	"cors-headers":            "MCP_CORS_HEADERS",
+	"cors-max-age":            "MCP_CORS_MAX_AGE",
+	"allowed-origins":         "MCP_ALLOWED_ORIGINS",
+	"log-format":              "MCP_LOG_FORMAT",
+	"metrics":                 "MCP_METRICS",
+	"debug":                   "MCP_DEBUG",
+	"otlp-endpoint":           "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"ping-interval":           "MCP_PING_INTERVAL",
+	"session-ttl":             "MCP_SESSION_TTL",
+	"rate-limit":              "MCP_RATE_LIMIT",
+	"rate-burst":              "MCP_RATE_BURST",
+	"casdoor-timeout":         "CASDOOR_TIMEOUT",
+	"casdoor-max-attempts":    "CASDOOR_MAX_ATTEMPTS",
+	"casdoor-retry-backoff":   "CASDOOR_RETRY_BACKOFF",
+	"tool-timeout":            "MCP_TOOL_TIMEOUT",
+	"slow-tool-ms":            "MCP_SLOW_TOOL_MS",
+	"sanitize-output":         "MCP_SANITIZE_OUTPUT",
+	"tool-cache-ttl":          "MCP_TOOL_CACHE_TTL",
+	"dedup-ttl":               "MCP_DEDUP_TTL",
+	"enabled-tools":           "MCP_ENABLED_TOOLS",
+	"disabled-tools":          "MCP_DISABLED_TOOLS",
+	"store-check-timeout":     "MCP_STORE_CHECK_TIMEOUT",
+}
+
+// applyConfigFile fills flags from a YAML or JSON file whose keys are flag
+// names, e.g. "casdoor-url" or "tool-timeout". A value is only used when the
+// flag wasn't given on the command line and its environment variable is
+// unset, so the order of precedence is flags, environment, file, defaults.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for key := range values {
+		if key == "config" || fs.Lookup(key) == nil {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("config %s: unknown keys: %s", path, strings.Join(unknown, ", "))
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if explicit[key] {
+			continue
+		}
+		if name, ok := flagEnv[key]; ok && os.Getenv(name) != "" {
+			continue
+		}
+
+		value, err := configScalar(values[key])
+		if err != nil {
+			return fmt.Errorf("config %s: %s: %w", path, key, err)
+		}
+		if err := fs.Set(key, value); err != nil {
+			return fmt.Errorf("config %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// readConfigFile decodes path as JSON when it ends in .json, otherwise as
+// YAML.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &values)
+	} else {
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// configScalar renders a decoded value the way it would be written on the
+// command line.
+func configScalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64:
+		return fmt.Sprint(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("want a string, number or boolean, got %T", v)
+}
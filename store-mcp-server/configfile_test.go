@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigFile(t *testing.T) {
+	yamlFile := writeFile(t, "mcp.yaml", "addr: \":9090\"\ntool-timeout: 5s\nrate-burst: 8\nmetrics: true\nrate-limit: 2.5\n")
+	jsonFile := writeFile(t, "mcp.json", `{"addr": ":9191", "tool-timeout": "7s", "rate-burst": 16}`)
+
+	tests := []struct {
+		name            string
+		args            []string
+		env             map[string]string
+		wantAddr        string
+		wantToolTimeout time.Duration
+		wantBurst       int
+	}{
+		{"yaml", []string{"-config", yamlFile}, nil, ":9090", 5 * time.Second, 8},
+		{"json", []string{"-config", jsonFile}, nil, ":9191", 7 * time.Second, 16},
+		{"config from env", nil, map[string]string{"MCP_CONFIG": yamlFile}, ":9090", 5 * time.Second, 8},
+		{"flag beats file", []string{"-config", yamlFile, "-addr", ":7070"}, nil, ":7070", 5 * time.Second, 8},
+		{"env beats file", []string{"-config", yamlFile}, map[string]string{"MCP_TOOL_TIMEOUT": "9s"}, ":9090", 9 * time.Second, 8},
+		{"defaults", nil, nil, ":8080", defaultToolTimeout, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := parseConfig(tt.args)
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			if cfg.Addr != tt.wantAddr || cfg.ToolTimeout != tt.wantToolTimeout || cfg.RateBurst != tt.wantBurst {
+				t.Errorf("addr %q, tool-timeout %s, rate-burst %d; want %q, %s, %d",
+					cfg.Addr, cfg.ToolTimeout, cfg.RateBurst, tt.wantAddr, tt.wantToolTimeout, tt.wantBurst)
+			}
+		})
+	}
+
+	cfg, err := parseConfig([]string{"-config", yamlFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Metrics || cfg.RateLimit != 2.5 {
+		t.Errorf("metrics %v, rate-limit %v; want true, 2.5", cfg.Metrics, cfg.RateLimit)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"unknown keys", "addr: \":1\"\nadress: \":2\"\nconfig: x\n", nil, "unknown keys: adress, config"},
+		{"bad value", "tool-timeout: soon\n", nil, "tool-timeout"},
+		{"not a scalar", "addr: [1, 2]\n", nil, "want a string, number or boolean"},
+		{"malformed duration env", "", map[string]string{"MCP_TOOL_TIMEOUT": "abc"}, "MCP_TOOL_TIMEOUT"},
+		{"malformed int env", "", map[string]string{"MCP_RATE_BURST": "lots"}, "MCP_RATE_BURST"},
+		{"malformed float env", "", map[string]string{"MCP_RATE_LIMIT": "fast"}, "MCP_RATE_LIMIT"},
+		{"malformed bool env", "", map[string]string{"MCP_METRICS": "yes please"}, "MCP_METRICS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var args []string
+			if tt.file != "" {
+				args = []string{"-config", writeFile(t, "mcp.yaml", tt.file)}
+			}
+			_, err := parseConfig(args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// flagEnv must agree with the "(env NAME)" each flag's help text shows, or
+// the help would describe a precedence the config file doesn't follow.
+func TestFlagEnv(t *testing.T) {
+	usage, err := os.CreateTemp(t.TempDir(), "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = usage
+	_, err = parseConfig([]string{"-h"})
+	os.Stderr = stderr
+	if err != flag.ErrHelp {
+		t.Fatalf("parseConfig -h: %v", err)
+	}
+	data, err := os.ReadFile(usage.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each flag prints as "  -name type" followed by indented help lines
+	documented := make(map[string]string)
+	var name string
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "  -"); ok {
+			name, _, _ = strings.Cut(rest, " ")
+		}
+		if _, env, ok := strings.Cut(line, "(env "); ok && name != "config" {
+			documented[name], _, _ = strings.Cut(env, ")")
+		}
+	}
+	if len(documented) == 0 {
+		t.Fatalf("no environment variables found in usage:\n%s", data)
+	}
+	if !reflect.DeepEqual(documented, flagEnv) {
+		for flagName, env := range documented {
+			if flagEnv[flagName] != env {
+				t.Errorf("-%s: help says %s, flagEnv has %q", flagName, env, flagEnv[flagName])
+			}
+		}
+		for flagName, env := range flagEnv {
+			if _, ok := documented[flagName]; !ok {
+				t.Errorf("flagEnv lists -%s (%s), which the help doesn't", flagName, env)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//
+// --------------------
+// CORS
+// --------------------
+//
+
+// CORSPolicy decides which browser origins may call the server. With no
+// origins configured every origin is allowed via "*".
+type CORSPolicy struct {
+	Origins []string
+	Methods string
+	Headers string
+	MaxAge  int // seconds browsers may cache a preflight; 0 omits it
+}
+
+// mcpHeaders are request headers the MCP transports rely on. They are always
+// allowed, even if a custom header list leaves them out.
+var mcpHeaders = []string{"Mcp-Session-Id"}
+
+func NewCORSPolicy(origins, methods, headers string, maxAge int) *CORSPolicy {
+	for _, h := range mcpHeaders {
+		switch {
+		case containsFold(strings.Split(headers, ","), h):
+		case strings.TrimSpace(headers) == "":
+			headers = h
+		default:
+			headers += ", " + h
+		}
+	}
+
+	p := &CORSPolicy{Methods: methods, Headers: headers, MaxAge: maxAge}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			p.Origins = append(p.Origins, strings.TrimRight(o, "/"))
+		}
+	}
+	return p
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" if the origin is not allowed.
+func (p *CORSPolicy) allowOrigin(origin string) string {
+	if len(p.Origins) == 0 {
+		return "*"
+	}
+	for _, o := range p.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// wrap sets the CORS response headers before calling next.
+func (p *CORSPolicy) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if len(p.Origins) > 0 {
+			h.Add("Vary", "Origin")
+		}
+		if allowed := p.allowOrigin(r.Header.Get("Origin")); allowed != "" {
+			h.Set("Access-Control-Allow-Origin", allowed)
+			h.Set("Access-Control-Allow-Headers", p.Headers)
+			h.Set("Access-Control-Allow-Methods", p.Methods)
+			h.Set("Access-Control-Expose-Headers", "Mcp-Session-Id")
+			if r.Method == http.MethodOptions && p.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAge))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func containsFold(list []string, want string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *CORSPolicy
+		method  string
+		maxAge  string
+		headers string
+	}{
+		{"preflight cached", NewCORSPolicy("", "POST, OPTIONS", "Content-Type, Authorization", 600), http.MethodOptions, "600", "Content-Type, Authorization, Mcp-Session-Id"},
+		{"zero max age omitted", NewCORSPolicy("", "POST, OPTIONS", "Content-Type", 0), http.MethodOptions, "", "Content-Type, Mcp-Session-Id"},
+		{"not on POST", NewCORSPolicy("", "POST, OPTIONS", "Content-Type", 600), http.MethodPost, "", "Content-Type, Mcp-Session-Id"},
+		{"session header kept once", NewCORSPolicy("", "POST", "mcp-session-id", 600), http.MethodOptions, "600", "mcp-session-id"},
+		{"empty header list", NewCORSPolicy("", "POST", "", 600), http.MethodOptions, "600", "Mcp-Session-Id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/mcp", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			rec := httptest.NewRecorder()
+			tt.policy.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != tt.maxAge {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, tt.maxAge)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != tt.headers {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, tt.headers)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+//
+// --------------------
+// Request deduplication
+// --------------------
+//
+
+// maxDedupEntries bounds the cache; once full, new requests run without
+// deduplication until old entries expire.
+const maxDedupEntries = 1000
+
+// dedupCall is one request seen on a session. done is closed once resp is
+// set; until then expires is zero and the entry never expires.
+type dedupCall struct {
+	method  string
+	resp    JSONRPCResponse
+	done    chan struct{}
+	expires time.Time
+}
+
+// DedupCache remembers replies by session and request id, so a client that
+// resends a request after a dropped connection gets the original reply
+// instead of running a tool twice. A retry that arrives while the first
+// attempt is still running waits for it.
+type DedupCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dedupCall
+}
+
+func NewDedupCache(ttl time.Duration) *DedupCache {
+	return &DedupCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*dedupCall),
+	}
+}
+
+// SetDedupCache enables deduplication of retried requests.
+func (s *MCPServer) SetDedupCache(c *DedupCache) {
+	s.dedup = c
+}
+
+// dedupKey identifies req within its session. It is empty for messages that
+// can't be retried: notifications, and requests outside a session.
+func dedupKey(ctx context.Context, req JSONRPCRequest) string {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return ""
+	}
+	switch id := req.ID.(type) {
+	case string:
+		return sess.ID + "\x00s" + id
+	case json.Number:
+		return sess.ID + "\x00n" + string(id)
+	default:
+		return ""
+	}
+}
+
+// begin registers a request. first is true when the caller should run it
+// and then call finish; otherwise call is an earlier request with the same
+// key to wait on. A nil call means deduplication doesn't apply: the cache
+// is nil or full, the key is empty, or the id was reused for another method.
+func (c *DedupCache) begin(key, method string) (call *dedupCall, first bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if call, ok := c.entries[key]; ok {
+		if call.expires.IsZero() || now.Before(call.expires) {
+			if call.method != method {
+				return nil, false
+			}
+			return call, false
+		}
+		delete(c.entries, key)
+	}
+
+	if len(c.entries) >= maxDedupEntries {
+		for k, call := range c.entries {
+			if !call.expires.IsZero() && now.After(call.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxDedupEntries {
+			return nil, false
+		}
+	}
+	call = &dedupCall{method: method, done: make(chan struct{})}
+	c.entries[key] = call
+	return call, true
+}
+
+// finish records the reply to call and releases anyone waiting on it.
+// Replies that a retry shouldn't see again, rate limiting and cancellation,
+// are handed to current waiters but then forgotten.
+func (c *DedupCache) finish(key string, call *dedupCall, resp JSONRPCResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	call.resp = resp
+	call.expires = c.now().Add(c.ttl)
+	close(call.done)
+	if resp.Error != nil && (resp.Error.Code == -32099 || resp.Error.Code == -32800) {
+		delete(c.entries, key)
+	}
+}
+
+// wait returns the reply to call once it is known, or false if ctx ends
+// first.
+func (call *dedupCall) wait(ctx context.Context) (JSONRPCResponse, bool) {
+	select {
+	case <-call.done:
+		return call.resp, true
+	case <-ctx.Done():
+		return JSONRPCResponse{}, false
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	const ttl = time.Minute
+	const first = `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"counter"}}`
+	tests := []struct {
+		name       string
+		second     string
+		newSession bool
+		advance    time.Duration
+		wantCalls  int
+		wantSame   bool
+	}{
+		{"duplicate id", first, false, 0, 1, true},
+		{"same id as string", `{"jsonrpc":"2.0","id":"5","method":"tools/call","params":{"name":"counter"}}`, false, 0, 2, false},
+		{"different id", `{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"counter"}}`, false, 0, 2, false},
+		{"other session", first, true, 0, 2, false},
+		{"after ttl", first, false, ttl + time.Second, 2, false},
+		{"id reused for another method", `{"jsonrpc":"2.0","id":5,"method":"ping"}`, false, 0, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			cache := NewDedupCache(ttl)
+			cache.now = func() time.Time { return now }
+			s := NewMCPServer()
+			s.SetDedupCache(cache)
+
+			calls := 0
+			err := s.RegisterTool(Tool{Name: "counter", InputSchema: InputSchema{Type: "object"}},
+				func(context.Context, map[string]interface{}) (CallToolResult, error) {
+					calls++
+					return CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(calls)}}}, nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := initialized(t, s)
+			resp1 := message(t, s, ctx, first)
+			now = now.Add(tt.advance)
+			if tt.newSession {
+				ctx = initialized(t, s)
+			}
+			resp2 := message(t, s, ctx, tt.second)
+
+			if calls != tt.wantCalls {
+				t.Errorf("tool ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if same := reflect.DeepEqual(resp1, resp2); same != tt.wantSame {
+				t.Errorf("second reply %+v same as first = %v, want %v", resp2, same, tt.wantSame)
+			}
+		})
+	}
+}
+
+// A retry that arrives while the first attempt is still running waits for
+// its reply rather than running the tool again.
+func TestDedupWaitsForRunningCall(t *testing.T) {
+	s := NewMCPServer()
+	s.SetDedupCache(NewDedupCache(time.Minute))
+	started, release := make(chan struct{}), make(chan struct{})
+	calls := 0
+	err := s.RegisterTool(Tool{Name: "slow", InputSchema: InputSchema{Type: "object"}},
+		func(context.Context, map[string]interface{}) (CallToolResult, error) {
+			calls++
+			close(started)
+			<-release
+			return CallToolResult{Content: []Content{{Type: "text", Text: "done"}}}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := initialized(t, s)
+	const req = `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"slow"}}`
+
+	replies := make(chan JSONRPCResponse, 2)
+	send := func() {
+		resp, _ := s.handleMessage(ctx, []byte(req))
+		replies <- resp.(JSONRPCResponse)
+	}
+	go send()
+	<-started
+	go send()
+	close(release)
+
+	a, b := <-replies, <-replies
+	if calls != 1 {
+		t.Errorf("tool ran %d times, want once", calls)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("replies differ: %+v and %+v", a, b)
+	}
+}
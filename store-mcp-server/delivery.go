@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+//
+// --------------------
+// Delivery checks
+// --------------------
+//
+
+// pincodePattern matches an Indian postal code: six digits, not starting
+// with 0.
+const pincodePattern = `^[1-9][0-9]{5}$`
+
+// Delivery is the result of check_delivery. Source names the checker that
+// answered, so clients can tell real data from the stub.
+type Delivery struct {
+	Store       string `json:"store"`
+	Pincode     string `json:"pincode"`
+	Deliverable bool   `json:"deliverable"`
+	Source      string `json:"source"`
+}
+
+// DeliveryChecker reports whether a store delivers to a pincode. pincode has
+// already been validated.
+type DeliveryChecker interface {
+	CheckDelivery(ctx context.Context, store Store, pincode string) (Delivery, error)
+}
+
+// StubDeliveryChecker answers without real serviceability data: every store
+// delivers everywhere.
+type StubDeliveryChecker struct{}
+
+func (StubDeliveryChecker) CheckDelivery(_ context.Context, store Store, pincode string) (Delivery, error) {
+	return Delivery{Store: store.Name, Pincode: pincode, Deliverable: true, Source: "stub"}, nil
+}
+
+// deliveryTool asks checker whether a store delivers to a pincode. The schema
+// has already rejected malformed pincodes.
+func (c *StoreCatalog) deliveryTool(checker DeliveryChecker) ToolHandler {
+	return func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+		name, _ := args["store"].(string)
+		pincode, _ := args["pincode"].(string)
+
+		store, ok := c.Find(name)
+		if !ok {
+			return CallToolResult{
+				Content: []Content{{Type: "text", Text: "store not found: " + name}},
+				IsError: true,
+			}, nil
+		}
+
+		delivery, err := checker.CheckDelivery(ctx, store, pincode)
+		if err != nil {
+			return CallToolResult{}, err
+		}
+		data, err := json.Marshal(delivery)
+		if err != nil {
+			return CallToolResult{}, err
+		}
+
+		summary := fmt.Sprintf("%s delivers to %s", store.Name, pincode)
+		if !delivery.Deliverable {
+			summary = fmt.Sprintf("%s does not deliver to %s", store.Name, pincode)
+		}
+		return CallToolResult{
+			Content: []Content{
+				{Type: "text", Text: string(data)},
+				{Type: "text", Text: summary},
+			},
+		}, nil
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// noDelivery is a DeliveryChecker for which no store delivers anywhere.
+type noDelivery struct{}
+
+func (noDelivery) CheckDelivery(_ context.Context, store Store, pincode string) (Delivery, error) {
+	return Delivery{Store: store.Name, Pincode: pincode, Source: "test"}, nil
+}
+
+func TestCheckDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		checker DeliveryChecker
+		store   string
+		pincode interface{}
+		code    int
+		want    Delivery
+		summary string
+	}{
+		{"stub", StubDeliveryChecker{}, "Flipkart", "560001", 0,
+			Delivery{Store: "Flipkart", Pincode: "560001", Deliverable: true, Source: "stub"}, "Flipkart delivers to 560001"},
+		{"other checker", noDelivery{}, "Myntra", "110001", 0,
+			Delivery{Store: "Myntra", Pincode: "110001", Source: "test"}, "Myntra does not deliver to 110001"},
+		{"five digits", StubDeliveryChecker{}, "Flipkart", "56000", -32602, Delivery{}, ""},
+		{"seven digits", StubDeliveryChecker{}, "Flipkart", "5600011", -32602, Delivery{}, ""},
+		{"leading zero", StubDeliveryChecker{}, "Flipkart", "060001", -32602, Delivery{}, ""},
+		{"letters", StubDeliveryChecker{}, "Flipkart", "56A001", -32602, Delivery{}, ""},
+		{"spaces", StubDeliveryChecker{}, "Flipkart", "560 001", -32602, Delivery{}, ""},
+		{"number", StubDeliveryChecker{}, "Flipkart", 560001, -32602, Delivery{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			if err := registerStoreTools(s, DefaultStoreCatalog(), &http.Client{}, time.Second, tt.checker); err != nil {
+				t.Fatal(err)
+			}
+			resp := callTool(t, s, initialized(t, s), "check_delivery", map[string]interface{}{"store": tt.store, "pincode": tt.pincode})
+			if tt.code != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Fatalf("error = %+v, want %d", resp.Error, tt.code)
+				}
+				return
+			}
+
+			result := toolResult(t, resp)
+			var got Delivery
+			if err := json.Unmarshal([]byte(result.Content[0].Text), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("delivery = %+v, want %+v", got, tt.want)
+			}
+			if result.Content[1].Text != tt.summary {
+				t.Errorf("summary = %q, want %q", result.Content[1].Text, tt.summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+//
+// --------------------
+// GST
+// --------------------
+//
+
+// gstSlabs are the GST rates, in percent, calculate_gst accepts.
+var gstSlabs = []interface{}{0, 5, 12, 18, 28}
+
+// GSTBreakdown is the result of calculate_gst. Amounts are in rupees,
+// rounded to the paisa. Intra-state supplies split the tax equally into
+// CGST and SGST.
+type GSTBreakdown struct {
+	Base  float64 `json:"base"`
+	Rate  float64 `json:"rate"`
+	Tax   float64 `json:"tax"`
+	CGST  float64 `json:"cgst"`
+	SGST  float64 `json:"sgst"`
+	Total float64 `json:"total"`
+}
+
+// roundPaise rounds a rupee amount to two decimals, halves away from zero.
+func roundPaise(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// calculateGST applies rate to amount. With inclusive, amount already
+// contains the tax and the base is worked back from it.
+func calculateGST(amount, rate float64, inclusive bool) GSTBreakdown {
+	var base, tax float64
+	if inclusive {
+		total := roundPaise(amount)
+		base = roundPaise(total * 100 / (100 + rate))
+		tax = roundPaise(total - base)
+	} else {
+		base = roundPaise(amount)
+		tax = roundPaise(base * rate / 100)
+	}
+
+	// Give SGST whatever CGST's rounding left, so the halves add up
+	cgst := roundPaise(tax / 2)
+	return GSTBreakdown{
+		Base:  base,
+		Rate:  rate,
+		Tax:   tax,
+		CGST:  cgst,
+		SGST:  roundPaise(tax - cgst),
+		Total: roundPaise(base + tax),
+	}
+}
+
+func calculateGSTTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	// The schema has already checked both against their types and the slabs
+	amount, _ := args["amount"].(float64)
+	rate, _ := args["rate"].(float64)
+	inclusive, _ := args["inclusive"].(bool)
+
+	gst := calculateGST(amount, rate, inclusive)
+	data, err := json.Marshal(gst)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+
+	base, _ := formatINR(gst.Base)
+	tax, _ := formatINR(gst.Tax)
+	total, _ := formatINR(gst.Total)
+	return CallToolResult{
+		Content: []Content{
+			{Type: "text", Text: string(data)},
+			{Type: "text", Text: fmt.Sprintf("%s + %v%% GST %s = %s", base, gst.Rate, tax, total)},
+		},
+	}, nil
+}
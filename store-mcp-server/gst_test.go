@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCalculateGST(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    float64
+		rate      float64
+		inclusive bool
+		want      GSTBreakdown
+	}{
+		{"0%", 1000, 0, false, GSTBreakdown{Base: 1000, Rate: 0, Tax: 0, CGST: 0, SGST: 0, Total: 1000}},
+		{"5%", 1000, 5, false, GSTBreakdown{Base: 1000, Rate: 5, Tax: 50, CGST: 25, SGST: 25, Total: 1050}},
+		{"12%", 1000, 12, false, GSTBreakdown{Base: 1000, Rate: 12, Tax: 120, CGST: 60, SGST: 60, Total: 1120}},
+		{"18%", 1000, 18, false, GSTBreakdown{Base: 1000, Rate: 18, Tax: 180, CGST: 90, SGST: 90, Total: 1180}},
+		{"28%", 1000, 28, false, GSTBreakdown{Base: 1000, Rate: 28, Tax: 280, CGST: 140, SGST: 140, Total: 1280}},
+		{"inclusive 18%", 1180, 18, true, GSTBreakdown{Base: 1000, Rate: 18, Tax: 180, CGST: 90, SGST: 90, Total: 1180}},
+		{"amount rounded to paise", 99.999, 5, false, GSTBreakdown{Base: 100, Rate: 5, Tax: 5, CGST: 2.5, SGST: 2.5, Total: 105}},
+		{"tax rounds half away from zero", 10.1, 5, false, GSTBreakdown{Base: 10.1, Rate: 5, Tax: 0.51, CGST: 0.26, SGST: 0.25, Total: 10.61}},
+		{"odd paisa split", 0.1, 18, false, GSTBreakdown{Base: 0.1, Rate: 18, Tax: 0.02, CGST: 0.01, SGST: 0.01, Total: 0.12}},
+		{"inclusive base rounded", 100, 12, true, GSTBreakdown{Base: 89.29, Rate: 12, Tax: 10.71, CGST: 5.36, SGST: 5.35, Total: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculateGST(tt.amount, tt.rate, tt.inclusive); got != tt.want {
+				t.Errorf("calculateGST(%v, %v, %v) = %+v, want %+v", tt.amount, tt.rate, tt.inclusive, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateGSTTool(t *testing.T) {
+	s, ctx := newStoreServer(t)
+
+	for _, slab := range gstSlabs {
+		rate := enumValue(slab).(float64)
+		result := toolResult(t, callTool(t, s, ctx, "calculate_gst", map[string]interface{}{"amount": 200.0, "rate": rate}))
+		var got GSTBreakdown
+		if err := json.Unmarshal([]byte(result.Content[0].Text), &got); err != nil {
+			t.Fatalf("rate %v: %v", rate, err)
+		}
+		if want := calculateGST(200, rate, false); got != want {
+			t.Errorf("rate %v: got %+v, want %+v", rate, got, want)
+		}
+	}
+
+	for _, rate := range []interface{}{7.0, "18"} {
+		resp := callTool(t, s, ctx, "calculate_gst", map[string]interface{}{"amount": 200.0, "rate": rate})
+		if resp.Error == nil || resp.Error.Code != -32602 {
+			t.Errorf("rate %#v: error = %+v, want -32602", rate, resp.Error)
+		}
+	}
+}
+
+// The slabs must be published as JSON numbers, or no number could satisfy
+// the schema.
+func TestGSTRateSchema(t *testing.T) {
+	s, _ := newStoreServer(t)
+	rt, ok, _ := s.lookupTool("calculate_gst")
+	if !ok {
+		t.Fatal("calculate_gst not registered")
+	}
+	data, err := json.Marshal(rt.tool.InputSchema.Properties["rate"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rate struct {
+		Enum []interface{} `json:"enum"`
+	}
+	json.Unmarshal(data, &rate)
+	if len(rate.Enum) != len(gstSlabs) {
+		t.Fatalf("enum = %v, want %d slabs", rate.Enum, len(gstSlabs))
+	}
+	for _, v := range rate.Enum {
+		if _, ok := v.(float64); !ok {
+			t.Errorf("enum value %#v is not a JSON number", v)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//
+// --------------------
+// INR formatting
+// --------------------
+//
+
+// formatINR writes amount in rupees using Indian digit grouping: the last
+// three digits, then groups of two (lakhs, crores), e.g. ₹12,34,567.
+// Amounts with paise keep two decimals; whole amounts have none.
+func formatINR(amount float64) (string, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return "", fmt.Errorf("amount must be a finite number")
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	whole, paise, _ := strings.Cut(strconv.FormatFloat(amount, 'f', 2, 64), ".")
+	if paise == "00" {
+		paise = ""
+	}
+	if whole == "0" && paise == "" {
+		sign = "" // rounding can leave -0.00
+	}
+
+	var b strings.Builder
+	b.WriteString(sign + "₹")
+	b.WriteString(groupIndian(whole))
+	if paise != "" {
+		b.WriteString("." + paise)
+	}
+	return b.String(), nil
+}
+
+// groupIndian inserts commas into a string of digits the Indian way.
+func groupIndian(digits string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	head, tail := digits[:len(digits)-3], digits[len(digits)-3:]
+
+	var groups []string
+	for len(head) > 2 {
+		groups = append([]string{head[len(head)-2:]}, groups...)
+		head = head[:len(head)-2]
+	}
+	groups = append([]string{head}, groups...)
+	return strings.Join(groups, ",") + "," + tail
+}
+
+func formatINRTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	amount, ok := args["amount"].(float64)
+	if !ok {
+		return CallToolResult{}, fmt.Errorf("amount must be a number")
+	}
+	text, err := formatINR(amount)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	return CallToolResult{
+		Content: []Content{{Type: "text", Text: text}},
+	}, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatINR(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "₹0"},
+		{5, "₹5"},
+		{999, "₹999"},
+		{1000, "₹1,000"},
+		{12345, "₹12,345"},
+		{100000, "₹1,00,000"},
+		{1234567, "₹12,34,567"},
+		{10000000, "₹1,00,00,000"},
+		{123456789012, "₹1,23,45,67,89,012"},
+		{1234.5, "₹1,234.50"},
+		{1234567.891, "₹12,34,567.89"},
+		{0.999, "₹1"},
+		{-1234567, "-₹12,34,567"},
+		{-0.5, "-₹0.50"},
+		{-0.001, "₹0"},
+	}
+	for _, tt := range tests {
+		got, err := formatINR(tt.amount)
+		if err != nil || got != tt.want {
+			t.Errorf("formatINR(%v) = %q, %v; want %q", tt.amount, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := formatINR(bad); err == nil {
+			t.Errorf("formatINR(%v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestFormatINRTool(t *testing.T) {
+	s, ctx := newStoreServer(t)
+	result := toolResult(t, callTool(t, s, ctx, "format_inr", map[string]interface{}{"amount": 1234567}))
+	if len(result.Content) != 1 || result.Content[0].Text != "₹12,34,567" {
+		t.Errorf("content = %+v, want ₹12,34,567", result.Content)
+	}
+
+	resp := callTool(t, s, ctx, "format_inr", map[string]interface{}{"amount": "lots"})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("non-number amount: error = %+v, want -32602", resp.Error)
+	}
+}
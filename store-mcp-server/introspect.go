@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// --------------------
+// Token introspection
+// --------------------
+//
+
+// Introspector validates opaque tokens by asking Casdoor's RFC 7662
+// introspection endpoint. Active tokens are cached briefly so a busy client
+// doesn't cost a round-trip per request.
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	audience     string
+	cacheTTL     time.Duration
+	client       *http.Client
+	now          func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]introspectionEntry
+}
+
+type introspectionEntry struct {
+	claims  *TokenClaims
+	expires time.Time
+}
+
+type introspectionResponse struct {
+	Active bool `json:"active"`
+	TokenClaims
+}
+
+func NewIntrospector(endpoint, clientID, clientSecret, audience string, cacheTTL time.Duration, client *http.Client) *Introspector {
+	return &Introspector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		audience:     audience,
+		cacheTTL:     cacheTTL,
+		client:       client,
+		now:          time.Now,
+		cache:        make(map[string]introspectionEntry),
+	}
+}
+
+func (i *Introspector) Validate(token string) (*TokenClaims, error) {
+	key := tokenCacheKey(token)
+	now := i.now()
+
+	i.mu.Lock()
+	entry, ok := i.cache[key]
+	i.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.claims, nil
+	}
+
+	claims, err := i.introspect(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	if i.audience != "" && len(claims.Audience) > 0 && !claims.Audience.contains(i.audience) {
+		return nil, errors.New("token not issued for this audience")
+	}
+
+	// Never cache past the token's own expiry
+	expires := now.Add(i.cacheTTL)
+	if claims.ExpiresAt != 0 {
+		if exp := time.Unix(claims.ExpiresAt, 0); exp.Before(expires) {
+			expires = exp
+		}
+	}
+	i.store(key, introspectionEntry{claims: claims, expires: expires}, now)
+	return claims, nil
+}
+
+func (i *Introspector) introspect(token string) (*TokenClaims, error) {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+	req, err := http.NewRequest(http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(i.clientID, i.clientSecret)
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect: unexpected status %s", resp.Status)
+	}
+
+	var result introspectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("introspect: decode response: %w", err)
+	}
+	if !result.Active {
+		return nil, errors.New("token is not active")
+	}
+	return &result.TokenClaims, nil
+}
+
+// store caches an entry, dropping expired ones so the map can't grow without
+// bound under a stream of distinct tokens.
+func (i *Introspector) store(key string, entry introspectionEntry, now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for k, e := range i.cache {
+		if !now.Before(e.expires) {
+			delete(i.cache, k)
+		}
+	}
+	i.cache[key] = entry
+}
+
+// tokenCacheKey avoids keeping raw bearer tokens in memory as map keys.
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
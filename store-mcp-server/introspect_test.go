@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIntrospectorValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"active", http.StatusOK, `{"active":true,"sub":"alice","aud":"store","scope":"read"}`, ""},
+		{"active without aud", http.StatusOK, `{"active":true,"sub":"alice"}`, ""},
+		{"inactive", http.StatusOK, `{"active":false}`, "token is not active"},
+		{"expired", http.StatusOK, fmt.Sprintf(`{"active":true,"sub":"alice","exp":%d}`, now.Unix()-1), "token expired"},
+		{"other audience", http.StatusOK, `{"active":true,"sub":"alice","aud":["other"]}`, "token not issued for this audience"},
+		{"server error", http.StatusInternalServerError, `{}`, "unexpected status"},
+		{"not JSON", http.StatusOK, `<html>`, "decode response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id, secret, ok := r.BasicAuth()
+				if !ok || id != "client" || secret != "secret" {
+					t.Errorf("basic auth = %q, %q, %v; want the client credentials", id, secret, ok)
+				}
+				if err := r.ParseForm(); err != nil || r.PostForm.Get("token") != "opaque" {
+					t.Errorf("form token = %q (%v), want opaque", r.PostForm.Get("token"), err)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			i := NewIntrospector(srv.URL, "client", "secret", "store", time.Minute, srv.Client())
+			i.now = func() time.Time { return now }
+			claims, err := i.Validate("opaque")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				if claims.Subject != "alice" {
+					t.Errorf("subject = %q, want alice", claims.Subject)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIntrospectorCache(t *testing.T) {
+	const ttl = time.Minute
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		body      string
+		advance   time.Duration
+		wantCalls int32
+	}{
+		{"cached", `{"active":true,"sub":"alice"}`, ttl / 2, 1},
+		{"cache expired", `{"active":true,"sub":"alice"}`, ttl, 2},
+		{"token expires before the cache would", fmt.Sprintf(`{"active":true,"sub":"alice","exp":%d}`, start.Unix()+10), 10 * time.Second, 2},
+		{"inactive isn't cached", `{"active":false}`, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			now := start
+			i := NewIntrospector(srv.URL, "client", "secret", "", ttl, srv.Client())
+			i.now = func() time.Time { return now }
+			i.Validate("opaque")
+			now = now.Add(tt.advance)
+			i.Validate("opaque")
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("introspected %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//
+// --------------------
+// JWKS
+// --------------------
+//
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// fetchJWKS downloads the key set at uri and returns the usable signing keys
+// indexed by kid.
+func fetchJWKS(client *http.Client, uri string) (map[string]interface{}, error) {
+	resp, err := client.Get(uri)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: unexpected status %s", resp.Status)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+//
+// --------------------
+// JWKS cache
+// --------------------
+//
+
+// minMissRefreshInterval bounds how often an unknown kid may force a refetch,
+// so a stream of tokens with bogus kids can't be used to hammer Casdoor.
+const minMissRefreshInterval = 30 * time.Second
+
+// JWKSCache keeps Casdoor's signing keys in memory, refreshing them once the
+// TTL has elapsed or when a token references a kid we haven't seen.
+type JWKSCache struct {
+	uri   string
+	ttl   time.Duration
+	fetch func(uri string) (map[string]interface{}, error)
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+	inflight    chan struct{}
+}
+
+func NewJWKSCache(uri string, ttl time.Duration, client *http.Client) *JWKSCache {
+	return &JWKSCache{
+		uri: uri,
+		ttl: ttl,
+		fetch: func(uri string) (map[string]interface{}, error) {
+			return fetchJWKS(client, uri)
+		},
+	}
+}
+
+// Warm loads the key set now instead of on the first lookup.
+func (c *JWKSCache) Warm() error {
+	return c.refresh()
+}
+
+// KeyFor returns the public key for kid, refreshing the key set if needed.
+func (c *JWKSCache) KeyFor(kid string) (interface{}, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > c.ttl
+	recentlyTried := time.Since(c.lastAttempt) < minMissRefreshInterval
+	c.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && !stale && recentlyTried {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if err := c.refresh(); err != nil {
+		// Keep serving a known key while Casdoor is unavailable
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// refresh refetches the key set. Concurrent callers share a single fetch
+// instead of each going to Casdoor.
+func (c *JWKSCache) refresh() error {
+	c.mu.Lock()
+	if ch := c.inflight; ch != nil {
+		c.mu.Unlock()
+		<-ch
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.lastErr
+	}
+	ch := make(chan struct{})
+	c.inflight = ch
+	c.mu.Unlock()
+
+	keys, err := c.fetch(c.uri)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if err == nil {
+		c.keys = keys
+		c.fetchedAt = now
+	}
+	c.lastAttempt = now
+	c.lastErr = err
+	c.inflight = nil
+	close(ch)
+	return err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchJWKS(t *testing.T) {
+	rsaKey, ecKey := testKeys(t)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := `{"keys":[
+		{"kty":"RSA","kid":"rsa","use":"sig","n":"` + b64(rsaKey.N.Bytes()) + `","e":"` + b64(big.NewInt(int64(rsaKey.E)).Bytes()) + `"},
+		{"kty":"EC","kid":"ec","crv":"P-256","x":"` + b64(ecKey.X.Bytes()) + `","y":"` + b64(ecKey.Y.Bytes()) + `"},
+		{"kty":"RSA","kid":"enc","use":"enc","n":"` + b64(rsaKey.N.Bytes()) + `","e":"AQAB"},
+		{"kty":"oct","kid":"hmac","k":"c2VjcmV0"},
+		{"kty":"EC","kid":"curve","crv":"P-192","x":"AA","y":"AA"}
+	]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(set))
+	}))
+	defer srv.Close()
+
+	keys, err := fetchJWKS(srv.Client(), srv.URL+"/jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("got %d keys, want only the rsa and ec signing keys: %v", len(keys), keys)
+	}
+	if k, ok := keys["rsa"].(*rsa.PublicKey); !ok || !k.Equal(&rsaKey.PublicKey) {
+		t.Errorf("rsa key = %v, want the test key", keys["rsa"])
+	}
+	if k, ok := keys["ec"].(*ecdsa.PublicKey); !ok || !k.Equal(&ecKey.PublicKey) {
+		t.Errorf("ec key = %v, want the test key", keys["ec"])
+	}
+
+	if _, err := fetchJWKS(srv.Client(), srv.URL+"/missing"); err == nil || !strings.Contains(err.Error(), "unexpected status") {
+		t.Errorf("missing key set: err = %v", err)
+	}
+}
+
+// countingFetch serves keys and counts how often it is asked.
+type countingFetch struct {
+	mu    sync.Mutex
+	calls int
+	keys  map[string]interface{}
+	err   error
+}
+
+func (f *countingFetch) fetch(string) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.keys, f.err
+}
+
+func TestJWKSCacheKeyFor(t *testing.T) {
+	key := "known key"
+	tests := []struct {
+		name string
+		// age of the key set and of the last fetch attempt before the lookup
+		fetchedAgo, attemptedAgo time.Duration
+		fetchErr                 error
+		kid                      string
+		wantFetches              int
+		wantErr                  bool
+	}{
+		{"fresh known kid", time.Minute, time.Minute, nil, "k1", 0, false},
+		{"stale known kid", 2 * time.Hour, 2 * time.Hour, nil, "k1", 1, false},
+		{"unknown kid refetches", time.Minute, time.Minute, nil, "k2", 1, true},
+		{"unknown kid soon after a fetch is throttled", time.Minute, time.Second, nil, "k2", 0, true},
+		{"stale kid served while Casdoor is down", 2 * time.Hour, 2 * time.Hour, errors.New("down"), "k1", 1, false},
+		{"unknown kid while Casdoor is down", time.Minute, time.Minute, errors.New("down"), "k2", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &countingFetch{keys: map[string]interface{}{"k1": key}, err: tt.fetchErr}
+			c := &JWKSCache{
+				ttl:         time.Hour,
+				fetch:       f.fetch,
+				keys:        map[string]interface{}{"k1": key},
+				fetchedAt:   time.Now().Add(-tt.fetchedAgo),
+				lastAttempt: time.Now().Add(-tt.attemptedAgo),
+			}
+			got, err := c.KeyFor(tt.kid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != key {
+				t.Errorf("key = %v, want %v", got, key)
+			}
+			if f.calls != tt.wantFetches {
+				t.Errorf("fetched %d times, want %d", f.calls, tt.wantFetches)
+			}
+		})
+	}
+}
+
+// A burst of tokens with unknown kids costs Casdoor one fetch, not one each.
+func TestJWKSCacheThrottlesUnknownKids(t *testing.T) {
+	f := &countingFetch{keys: map[string]interface{}{"k1": "key"}}
+	c := &JWKSCache{ttl: time.Hour, fetch: f.fetch}
+
+	if _, err := c.KeyFor("k1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, kid := range []string{"bogus1", "bogus2", "bogus3"} {
+		if _, err := c.KeyFor(kid); err == nil {
+			t.Errorf("KeyFor(%s) succeeded", kid)
+		}
+	}
+	if f.calls != 1 {
+		t.Errorf("fetched %d times, want once", f.calls)
+	}
+
+	// Once the throttle interval has passed, an unknown kid may fetch again
+	c.mu.Lock()
+	c.lastAttempt = time.Now().Add(-minMissRefreshInterval)
+	c.mu.Unlock()
+	f.keys = map[string]interface{}{"k1": "key", "k2": "rotated"}
+	if got, err := c.KeyFor("k2"); err != nil || got != "rotated" {
+		t.Errorf("KeyFor(k2) = %v, %v; want the rotated key", got, err)
+	}
+	if f.calls != 2 {
+		t.Errorf("fetched %d times, want twice", f.calls)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticKeys is a keySource over a fixed set of keys.
+type staticKeys map[string]interface{}
+
+func (k staticKeys) KeyFor(kid string) (interface{}, error) {
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// signJWT returns a compact JWT for header and claims signed with key, an
+// *rsa.PrivateKey (RS256) or *ecdsa.PrivateKey (ES256). A nil key leaves the
+// signature empty.
+func signJWT(t *testing.T, header map[string]string, claims interface{}, key interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(header) + "." + segment(claims)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest.Sum(nil)); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func testKeys(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rsaKey, ecKey
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, ecKey := testKeys(t)
+	otherRSA, _ := testKeys(t)
+	keys := staticKeys{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}
+	claims := map[string]interface{}{"sub": "alice", "iss": "https://casdoor.example.com", "aud": "store", "exp": 2000000000}
+
+	valid := signJWT(t, map[string]string{"alg": "RS256", "kid": "rsa"}, claims, rsaKey)
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","exp":2000000000}`)) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"RS256", valid, ""},
+		{"ES256", signJWT(t, map[string]string{"alg": "ES256", "kid": "ec"}, claims, ecKey), ""},
+		{"tampered claims", tampered, "invalid signature"},
+		{"signed by another key", signJWT(t, map[string]string{"alg": "RS256", "kid": "rsa"}, claims, otherRSA), "invalid signature"},
+		{"alg none", signJWT(t, map[string]string{"alg": "none", "kid": "rsa"}, claims, nil), `unsupported signing algorithm "none"`},
+		{"HS256", signJWT(t, map[string]string{"alg": "HS256", "kid": "rsa"}, claims, nil), `unsupported signing algorithm "HS256"`},
+		{"RS256 header on EC key", signJWT(t, map[string]string{"alg": "RS256", "kid": "ec"}, claims, rsaKey), `algorithm "RS256" does not match EC key`},
+		{"ES256 header on RSA key", signJWT(t, map[string]string{"alg": "ES256", "kid": "rsa"}, claims, ecKey), `algorithm "ES256" does not match RSA key`},
+		{"unknown kid", signJWT(t, map[string]string{"alg": "RS256", "kid": "gone"}, claims, rsaKey), `unknown signing key "gone"`},
+		{"two segments", parts[0] + "." + parts[1], "malformed token"},
+		{"bad header", "!." + parts[1] + "." + parts[2], "malformed header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyJWT(tt.token, keys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verifyJWT: %v", err)
+				}
+				if got.Subject != "alice" {
+					t.Errorf("subject = %q, want alice", got.Subject)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClaimsValidate(t *testing.T) {
+	const issuer, aud = "https://casdoor.example.com", "store"
+	now := time.Unix(1700000000, 0)
+	good := func() TokenClaims {
+		return TokenClaims{Issuer: issuer, Audience: audience{aud}, ExpiresAt: now.Unix() + 60}
+	}
+	tests := []struct {
+		name    string
+		edit    func(*TokenClaims)
+		wantErr string
+	}{
+		{"valid", func(*TokenClaims) {}, ""},
+		{"audience among several", func(c *TokenClaims) { c.Audience = audience{"other", aud} }, ""},
+		{"not before has passed", func(c *TokenClaims) { c.NotBefore = now.Unix() - 1 }, ""},
+		{"expired", func(c *TokenClaims) { c.ExpiresAt = now.Unix() - 1 }, "token expired"},
+		{"expires now", func(c *TokenClaims) { c.ExpiresAt = now.Unix() }, "token expired"},
+		{"no exp", func(c *TokenClaims) { c.ExpiresAt = 0 }, "token expired"},
+		{"not yet valid", func(c *TokenClaims) { c.NotBefore = now.Unix() + 60 }, "token not yet valid"},
+		{"wrong issuer", func(c *TokenClaims) { c.Issuer = "https://evil.example.com" }, "unexpected issuer"},
+		{"wrong audience", func(c *TokenClaims) { c.Audience = audience{"other"} }, "token not issued for this audience"},
+		{"no audience", func(c *TokenClaims) { c.Audience = nil }, "token not issued for this audience"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := good()
+			tt.edit(&c)
+			err := c.validate(issuer, aud, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want audience
+	}{
+		{`{"aud":"store"}`, audience{"store"}},
+		{`{"aud":["store","admin"]}`, audience{"store", "admin"}},
+		{`{}`, nil},
+	}
+	for _, tt := range tests {
+		var c TokenClaims
+		if err := json.Unmarshal([]byte(tt.json), &c); err != nil {
+			t.Fatalf("%s: %v", tt.json, err)
+		}
+		if strings.Join(c.Audience, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: audience = %v, want %v", tt.json, c.Audience, tt.want)
+		}
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	const issuer, aud = "https://casdoor.example.com", "store"
+	rsaKey, _ := testKeys(t)
+	validator := NewJWTValidator(staticKeys{"k": &rsaKey.PublicKey}, issuer, aud)
+	token := func(claims map[string]interface{}) string {
+		return signJWT(t, map[string]string{"alg": "RS256", "kid": "k"}, claims, rsaKey)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		wantCode      int
+		wantChallenge string
+	}{
+		{"valid token", http.MethodPost, "Bearer " + token(map[string]interface{}{"sub": "alice", "iss": issuer, "aud": aud, "exp": exp}), http.StatusOK, ""},
+		{"lower-case scheme", http.MethodPost, "bearer " + token(map[string]interface{}{"sub": "alice", "iss": issuer, "aud": aud, "exp": exp}), http.StatusOK, ""},
+		{"no header", http.MethodPost, "", http.StatusUnauthorized, `Bearer realm="mcp"`},
+		{"basic auth", http.MethodPost, "Basic YTpi", http.StatusUnauthorized, `Bearer realm="mcp"`},
+		{"empty token", http.MethodPost, "Bearer ", http.StatusUnauthorized, `Bearer realm="mcp"`},
+		{"expired", http.MethodPost, "Bearer " + token(map[string]interface{}{"sub": "alice", "iss": issuer, "aud": aud, "exp": 1}), http.StatusUnauthorized, `error="invalid_token"`},
+		{"wrong audience", http.MethodPost, "Bearer " + token(map[string]interface{}{"sub": "alice", "iss": issuer, "aud": "other", "exp": exp}), http.StatusUnauthorized, `error="invalid_token"`},
+		{"garbage", http.MethodPost, "Bearer not-a-jwt", http.StatusUnauthorized, `error="invalid_token"`},
+		{"preflight", http.MethodOptions, "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			handler := NewAuthenticator(validator).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if claims, ok := claimsFromContext(r.Context()); ok {
+					subject = claims.Subject
+				}
+			}))
+			req := httptest.NewRequest(tt.method, "/mcp", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if !strings.Contains(challenge, tt.wantChallenge) || (tt.wantChallenge == "") != (challenge == "") {
+				t.Errorf("WWW-Authenticate = %q, want it to contain %q", challenge, tt.wantChallenge)
+			}
+			if tt.wantCode == http.StatusOK && tt.method == http.MethodPost && subject != "alice" {
+				t.Errorf("handler saw subject %q, want alice", subject)
+			}
+		})
+	}
+}
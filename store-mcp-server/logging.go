@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//
+// --------------------
+// Logging
+// --------------------
+//
+
+// setupLogging installs a slog handler writing to stderr in the given format
+// and routes the standard log package through it.
+//
+// Records logged with a context that can reach an MCP client are also sent
+// to it as notifications/message, filtered by that client's own level.
+func setupLogging(format string) error {
+	opts := &slog.HandlerOptions{}
+
+	var handler slog.Handler
+	switch format {
+	case "text", "":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	slog.SetDefault(slog.New(clientLogHandler{handler}))
+	return nil
+}
+
+// LoggingCapability advertises logging/setLevel and notifications/message.
+type LoggingCapability struct{}
+
+type SetLevelParams struct {
+	Level string `json:"level"`
+}
+
+type LogMessageParams struct {
+	Level  string                 `json:"level"`
+	Logger string                 `json:"logger,omitempty"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// mcpLevels maps the syslog-style levels of the MCP spec onto slog's.
+var mcpLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError,
+	"alert":     slog.LevelError,
+	"emergency": slog.LevelError,
+}
+
+func mcpLevelName(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "error"
+	case l >= slog.LevelWarn:
+		return "warning"
+	case l >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// handleSetLevel sets the level of the log messages sent to the calling
+// session. The server's own log is unaffected.
+func (s *MCPServer) handleSetLevel(ctx context.Context, id interface{}, params json.RawMessage) JSONRPCResponse {
+	var p SetLevelParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return newInvalidParams(id, err)
+	}
+	level, ok := mcpLevels[p.Level]
+	if !ok {
+		return s.sendError(id, -32602, "Invalid log level: "+p.Level, nil)
+	}
+
+	if sess := sessionFromContext(ctx); sess != nil {
+		sess.logLevel.Set(level)
+	}
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  map[string]string{},
+	}
+}
+
+// clientLogHandler forwards records to the MCP client behind the context
+// they were logged with, if the transport can notify it and the record is
+// at or above the client's level.
+type clientLogHandler struct {
+	slog.Handler
+}
+
+// clientNotifier returns how to reach the client behind ctx if it wants
+// records at level l.
+func clientNotifier(ctx context.Context, l slog.Level) (notifier, bool) {
+	notify, ok := ctx.Value(notifierContextKey).(notifier)
+	if !ok {
+		return nil, false
+	}
+	sess := sessionFromContext(ctx)
+	return notify, sess == nil || l >= sess.logLevel.Level()
+}
+
+func (h clientLogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	if _, ok := clientNotifier(ctx, l); ok {
+		return true
+	}
+	return h.Handler.Enabled(ctx, l)
+}
+
+func (h clientLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if notify, ok := clientNotifier(ctx, r.Level); ok {
+		data := map[string]interface{}{"message": r.Message}
+		r.Attrs(func(a slog.Attr) bool {
+			data[a.Key] = a.Value.String()
+			return true
+		})
+		notify(JSONRPCNotification{
+			JsonRPC: "2.0",
+			Method:  "notifications/message",
+			Params: LogMessageParams{
+				Level:  mcpLevelName(r.Level),
+				Logger: defaultServerName,
+				Data:   data,
+			},
+		})
+	}
+	if !h.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h clientLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return clientLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h clientLogHandler) WithGroup(name string) slog.Handler {
+	return clientLogHandler{h.Handler.WithGroup(name)}
+}
+
+// requestInfo collects what the access log needs to know about one /mcp
+// request. Handlers fill in the RPC details as they learn them.
+type requestInfo struct {
+	ID        string
+	RPCMethod string
+	ErrorCode int
+}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoContextKey).(*requestInfo)
+	return info
+}
+
+// newRequestID returns a random RFC 4122 version 4 UUID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logRequests assigns each request an ID, echoes it in X-Request-Id and logs
+// one line per request once it completes.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &requestInfo{ID: newRequestID()}
+		w.Header().Set("X-Request-Id", info.ID)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		attrs := []slog.Attr{
+			slog.String("request_id", info.ID),
+			slog.String("http_method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if info.RPCMethod != "" {
+			attrs = append(attrs, slog.String("rpc_method", info.RPCMethod))
+		}
+		if info.ErrorCode != 0 {
+			attrs = append(attrs, slog.String("outcome", "error"), slog.Int("error_code", info.ErrorCode))
+		} else {
+			attrs = append(attrs, slog.String("outcome", "success"))
+		}
+		slog.LogAttrs(ctx, slog.LevelInfo, "mcp request", attrs...)
+	})
+}
+
+// rpcMethodOf extracts the method name of a raw message for logging.
+func rpcMethodOf(body []byte) string {
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		return "batch"
+	}
+	var peek struct {
+		Method string `json:"method"`
+	}
+	json.Unmarshal(body, &peek)
+	return peek.Method
+}
+
+// withRequestID adds a requestId field to an error's data object, next to
+// its typed fields, so clients can quote it when reporting problems and
+// still decode the data the same as on other transports. Data that isn't
+// an object is kept under detail.
+func withRequestID(data interface{}, id string) interface{} {
+	fields := map[string]json.RawMessage{}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return data
+		}
+		if json.Unmarshal(raw, &fields) != nil {
+			fields = map[string]json.RawMessage{"detail": raw}
+		}
+	}
+	fields["requestId"], _ = json.Marshal(id)
+	return fields
+}
+
+// annotateErrors records the first error code in info and tags every error
+// in resp with the request ID.
+func annotateErrors(resp interface{}, info *requestInfo) interface{} {
+	if info == nil {
+		return resp
+	}
+
+	tag := func(r JSONRPCResponse) JSONRPCResponse {
+		if r.Error == nil {
+			return r
+		}
+		if info.ErrorCode == 0 {
+			info.ErrorCode = r.Error.Code
+		}
+		e := *r.Error
+		e.Data = withRequestID(e.Data, info.ID)
+		r.Error = &e
+		return r
+	}
+
+	switch v := resp.(type) {
+	case JSONRPCResponse:
+		return tag(v)
+	case []JSONRPCResponse:
+		tagged := make([]JSONRPCResponse, len(v))
+		for i, r := range v {
+			tagged[i] = tag(r)
+		}
+		return tagged
+	default:
+		return resp
+	}
+}
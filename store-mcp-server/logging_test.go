@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// notifiedSession returns a context for a new initialized session whose log
+// notifications are appended to msgs.
+func notifiedSession(t *testing.T, s *MCPServer, msgs *[]string) context.Context {
+	t.Helper()
+	ctx := initialized(t, s)
+	return withNotifier(ctx, func(n JSONRPCNotification) {
+		if p, ok := n.Params.(LogMessageParams); ok {
+			*msgs = append(*msgs, p.Level+":"+p.Data["message"].(string))
+		}
+	})
+}
+
+func TestSetLevelFiltersPerSession(t *testing.T) {
+	s := NewMCPServer()
+	logger := slog.New(clientLogHandler{slog.NewTextHandler(io.Discard, nil)})
+
+	var quiet, verbose, untouched []string
+	quietCtx := notifiedSession(t, s, &quiet)
+	verboseCtx := notifiedSession(t, s, &verbose)
+	untouchedCtx := notifiedSession(t, s, &untouched)
+
+	for ctx, level := range map[context.Context]string{quietCtx: "error", verboseCtx: "debug"} {
+		if resp := call(t, s, ctx, "logging/setLevel", map[string]string{"level": level}); resp.Error != nil {
+			t.Fatalf("setLevel %s: %+v", level, resp.Error)
+		}
+	}
+
+	for _, ctx := range []context.Context{quietCtx, verboseCtx, untouchedCtx} {
+		logger.DebugContext(ctx, "d")
+		logger.InfoContext(ctx, "i")
+		logger.WarnContext(ctx, "w")
+		logger.ErrorContext(ctx, "e")
+	}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"error", quiet, []string{"error:e"}},
+		{"debug", verbose, []string{"debug:d", "info:i", "warning:w", "error:e"}},
+		{"default", untouched, []string{"info:i", "warning:w", "error:e"}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s session got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSetLevelRejectsUnknownLevel(t *testing.T) {
+	s := NewMCPServer()
+	resp := call(t, s, initialized(t, s), "logging/setLevel", map[string]string{"level": "verbose"})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("error = %+v, want -32602", resp.Error)
+	}
+}
+
+func TestAnnotateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		want map[string]interface{}
+	}{
+		{"no data", nil, map[string]interface{}{"requestId": "req-1"}},
+		{"detail", DetailErrorData{Detail: "bad"}, map[string]interface{}{"requestId": "req-1", "detail": "bad"}},
+		{"version", VersionErrorData{Requested: "1999", Supported: []string{"2025-03-26"}},
+			map[string]interface{}{"requestId": "req-1", "requested": "1999", "supported": []interface{}{"2025-03-26"}}},
+		{"not an object", "text", map[string]interface{}{"requestId": "req-1", "detail": "text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &requestInfo{ID: "req-1"}
+			resp := annotateErrors(newRPCError(1, -32602, "Invalid params", tt.data), info).(JSONRPCResponse)
+			if info.ErrorCode != -32602 {
+				t.Errorf("ErrorCode = %d, want -32602", info.ErrorCode)
+			}
+
+			raw, _ := json.Marshal(resp.Error.Data)
+			var got map[string]interface{}
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatalf("data %s: %v", raw, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("data = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Over HTTP the typed data keeps its shape, with the request ID beside it.
+func TestHTTPErrorDataHasRequestID(t *testing.T) {
+	srv := httptest.NewServer(logRequests(http.HandlerFunc(NewMCPServer().handleMCPRequest)))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Error struct {
+			Data struct {
+				VersionErrorData
+				RequestID string `json:"requestId"`
+			} `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	data := reply.Error.Data
+	if data.Requested != "1999-01-01" || len(data.Supported) == 0 {
+		t.Errorf("version data = %+v, want the typed fields at the top level", data.VersionErrorData)
+	}
+	if id := resp.Header.Get("X-Request-Id"); id == "" || data.RequestID != id {
+		t.Errorf("requestId = %q, X-Request-Id = %q; want them equal and set", data.RequestID, id)
+	}
+}
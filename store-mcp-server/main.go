@@ -1,12 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
-	"sync"
-	"strings"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //
@@ -22,9 +44,67 @@ type JSONRPCRequest struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 }
 
+// UnmarshalJSON decodes the id as a string or json.Number, so numeric ids
+// are echoed back exactly as sent rather than via float64. A missing or null
+// id leaves ID nil.
+func (r *JSONRPCRequest) UnmarshalJSON(data []byte) error {
+	type plain JSONRPCRequest
+	var raw struct {
+		plain
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	id, err := parseRequestID(raw.ID)
+	if err != nil {
+		return err
+	}
+	*r = JSONRPCRequest(raw.plain)
+	r.ID = id
+	return nil
+}
+
+// errInvalidID marks a request whose id is neither a string, a number nor
+// null.
+var errInvalidID = errors.New("id must be a string, number or null")
+
+func parseRequestID(raw json.RawMessage) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	switch raw[0] {
+	case '"':
+		var id string
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, err
+		}
+		return id, nil
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		var id json.Number
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	return nil, errInvalidID
+}
+
+// invalidRequestDetail explains why a request couldn't be decoded, when
+// that's something the client can fix.
+func invalidRequestDetail(err error) string {
+	if errors.Is(err, errInvalidID) {
+		return err.Error()
+	}
+	return ""
+}
+
+// The id is always present in responses; errors about requests whose id
+// couldn't be read carry "id": null.
 type JSONRPCResponse struct {
 	JsonRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id,omitempty"`
+	ID      interface{} `json:"id"`
 	Result  interface{} `json:"result,omitempty"`
 	Error   *RPCError   `json:"error,omitempty"`
 }
@@ -63,8 +143,17 @@ type InitializeResult struct {
 	ServerInfo      ServerInfo         `json:"serverInfo"`
 }
 
+// CapabilitiesResult is the reply to capabilities/get.
+type CapabilitiesResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
 type ServerCapabilities struct {
-	Tools *ToolsCapability `json:"tools,omitempty"`
+	Tools       *ToolsCapability       `json:"tools,omitempty"`
+	Resources   *ResourcesCapability   `json:"resources,omitempty"`
+	Prompts     *PromptsCapability     `json:"prompts,omitempty"`
+	Logging     *LoggingCapability     `json:"logging,omitempty"`
+	Completions *CompletionsCapability `json:"completions,omitempty"`
 }
 
 type ToolsCapability struct {
@@ -80,35 +169,84 @@ type Tool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	InputSchema InputSchema `json:"inputSchema"`
+
+	// Cacheable marks a tool whose result depends only on its arguments,
+	// so it may be served from the result cache.
+	Cacheable bool `json:"-"`
 }
 
 type InputSchema struct {
 	Type       string              `json:"type"`
 	Properties map[string]Property `json:"properties,omitempty"`
+	Required   []string            `json:"required,omitempty"`
 }
 
 type Property struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// Enum values must have the property's type: strings for "string",
+	// float64 or int for "number" and "integer".
+	Enum    []interface{} `json:"enum,omitempty"`
+	Minimum *float64      `json:"minimum,omitempty"`
+	// Pattern is a regular expression string values must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Items and MinItems constrain "array" properties.
+	Items    *Property `json:"items,omitempty"`
+	MinItems *int      `json:"minItems,omitempty"`
 }
 
 type ToolsListResult struct {
-	Tools []Tool `json:"tools"`
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type CallToolParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	// ValidateOnly checks the arguments against the tool's schema without
+	// running it.
+	ValidateOnly bool `json:"validateOnly,omitempty"`
+	// Meta is the request's "_meta" object, e.g. {"progressToken": 1}.
+	Meta map[string]interface{} `json:"_meta,omitempty"`
 }
 
 type CallToolResult struct {
 	Content []Content `json:"content"`
 	IsError bool      `json:"isError,omitempty"`
+	// Meta carries implementation-specific data back to the client.
+	Meta map[string]interface{} `json:"_meta,omitempty"`
 }
 
+// Content is one item of a tool result: "text" uses Text, "image" carries
+// base64 Data with its MimeType, and "resource" embeds Resource.
 type Content struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// MarshalJSON always emits "text" on text items, even when empty, since
+// clients expect it there.
+func (c Content) MarshalJSON() ([]byte, error) {
+	type plain Content
+	if c.Type != "text" {
+		return json.Marshal(plain(c))
+	}
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{c.Type, c.Text})
+}
+
+// imageContent wraps raw image bytes as a base64 content item.
+func imageContent(data []byte, mimeType string) Content {
+	return Content{
+		Type:     "image",
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MimeType: mimeType,
+	}
 }
 
 //
@@ -117,48 +255,353 @@ type Content struct {
 // --------------------
 //
 
+// ToolHandler executes a tool call with the client-supplied arguments. ctx is
+// cancelled if the client cancels the call; long-running handlers should
+// stop when ctx.Done() is closed.
+//
+// A returned error is reported to the client as a result with IsError set,
+// as is a result the handler marks IsError itself (e.g. store not found).
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (CallToolResult, error)
+
+type registeredTool struct {
+	tool           Tool
+	handler        ToolHandler
+	requiredScopes []string
+	// patterns holds each property's Pattern, compiled once at registration
+	patterns map[string]*regexp.Regexp
+}
+
+// lifecycleState tracks the MCP initialization handshake.
+type lifecycleState int
+
+const (
+	// stateNew: no initialize request has been handled yet.
+	stateNew lifecycleState = iota
+	// stateInitializing: initialize was answered; waiting for the client's
+	// notifications/initialized.
+	stateInitializing
+	// stateReady: the client confirmed with notifications/initialized.
+	stateReady
+)
+
 type MCPServer struct {
-	initialized bool
+	info        ServerInfo
+	tools       map[string]*registeredTool
+	toolOrder   []string
+	toolAllow   map[string]bool
+	toolDeny    map[string]bool
+	resources   ResourceProvider
+	completions CompletionProvider
+	prompts     map[string]*registeredPrompt
+	promptOrder []string
+	metrics     *Metrics
+	counters    *Stats
+	limiter     *RateLimiter
+	audit       *AuditLog
+	sessions    map[string]*Session
+	subscribers map[*Session]notifier
+	toolTimeout time.Duration
+	slowTool    time.Duration
+	sanitize    string
+	cache       *ResultCache
+	dedup       *DedupCache
+	adminScope  string
+	shutdown    func()
 	mu          sync.RWMutex
 }
 
 func NewMCPServer() *MCPServer {
-	return &MCPServer{}
+	return &MCPServer{
+		info:        ServerInfo{Name: defaultServerName, Version: versionString()},
+		tools:       make(map[string]*registeredTool),
+		prompts:     make(map[string]*registeredPrompt),
+		sessions:    make(map[string]*Session),
+		subscribers: make(map[*Session]notifier),
+		toolTimeout: defaultToolTimeout,
+		counters:    NewStats(),
+	}
+}
+
+// defaultToolTimeout bounds a tool call unless SetToolTimeout says otherwise.
+const defaultToolTimeout = 30 * time.Second
+
+// SetToolTimeout limits how long one tool call may run; its context is
+// cancelled when the time is up. Zero means no limit.
+func (s *MCPServer) SetToolTimeout(d time.Duration) {
+	s.toolTimeout = d
+}
+
+// SetSlowToolThreshold makes tool calls that take longer than d log a
+// warning. Zero turns the warning off.
+func (s *MCPServer) SetSlowToolThreshold(d time.Duration) {
+	s.slowTool = d
+}
+
+// SetName overrides the name reported in ServerInfo. It must be called
+// before the server starts handling requests.
+func (s *MCPServer) SetName(name string) {
+	s.info.Name = name
+}
+
+// RegisterTool makes a tool available to tools/list and tools/call. Tools are
+// listed in registration order; registering a name twice is an error. When
+// the caller is authenticated, its token must carry every one of
+// requiredScopes to call the tool.
+func (s *MCPServer) RegisterTool(t Tool, handler ToolHandler, requiredScopes ...string) error {
+	if t.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if handler == nil {
+		return fmt.Errorf("tool %q: handler is required", t.Name)
+	}
+	for _, name := range t.InputSchema.Required {
+		if _, ok := t.InputSchema.Properties[name]; !ok {
+			return fmt.Errorf("tool %q: required property %q is not declared", t.Name, name)
+		}
+	}
+	patterns := make(map[string]*regexp.Regexp)
+	for name, prop := range t.InputSchema.Properties {
+		if prop.Pattern != "" {
+			re, err := regexp.Compile(prop.Pattern)
+			if err != nil {
+				return fmt.Errorf("tool %q: property %q: %w", t.Name, name, err)
+			}
+			patterns[name] = re
+		}
+		for _, v := range prop.Enum {
+			if !matchesType(prop.Type, enumValue(v)) {
+				return fmt.Errorf("tool %q: property %q: enum value %#v is not of type %s", t.Name, name, v, prop.Type)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	if _, exists := s.tools[t.Name]; exists {
+		s.mu.Unlock()
+		return fmt.Errorf("tool %q already registered", t.Name)
+	}
+	s.tools[t.Name] = &registeredTool{tool: t, handler: handler, requiredScopes: requiredScopes, patterns: patterns}
+	s.toolOrder = append(s.toolOrder, t.Name)
+	s.mu.Unlock()
+
+	s.broadcast("notifications/tools/list_changed")
+	return nil
+}
+
+// lookupTool finds a registered tool; enabled is false if RestrictTools
+// hides it.
+func (s *MCPServer) lookupTool(name string) (rt *registeredTool, ok, enabled bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rt, ok = s.tools[name]
+	return rt, ok, ok && s.toolEnabled(name)
 }
 
 func (s *MCPServer) sendError(id interface{}, code int, message string, data interface{}) JSONRPCResponse {
-	return JSONRPCResponse{
-		JsonRPC: "2.0",
-		ID:      id,
-		Error: &RPCError{
-			Code:    code,
-			Message: message,
-			Data:    data,
-		},
+	return newRPCError(id, code, message, data)
+}
+
+// knownMethods are the methods dispatch handles; anything else is reported
+// to metrics as "unknown" to keep label cardinality bounded.
+var knownMethods = map[string]bool{
+	"initialize":                true,
+	"notifications/initialized": true,
+	"notifications/cancelled":   true,
+	"tools/list":                true,
+	"tools/call":                true,
+	"resources/list":            true,
+	"resources/read":            true,
+	"resources/templates/list":  true,
+	"prompts/list":              true,
+	"prompts/get":               true,
+	"ping":                      true,
+	"logging/setLevel":          true,
+	"completion/complete":       true,
+	"capabilities/get":          true,
+	"admin/shutdown":            true,
+}
+
+// supportedMethods lists the request methods clients may call, sorted.
+// Notifications are left out since they never get a reply to report on, and
+// admin methods since they are only for operators.
+func supportedMethods() []string {
+	methods := make([]string, 0, len(knownMethods))
+	for m := range knownMethods {
+		if !strings.HasPrefix(m, "notifications/") && !strings.HasPrefix(m, "admin/") {
+			methods = append(methods, m)
+		}
 	}
+	sort.Strings(methods)
+	return methods
 }
 
-func (s *MCPServer) handleRequest(req JSONRPCRequest) JSONRPCResponse {
+// isNotification reports whether req expects no reply. Any well-formed
+// request without an id is a notification, whatever its method; malformed
+// ones still get an error so the client learns what went wrong.
+func isNotification(req JSONRPCRequest) bool {
+	return req.ID == nil && validateRequest(req) == nil
+}
+
+// validateRequest checks the parts of a request every method relies on. The
+// error explains the problem to the client.
+func validateRequest(req JSONRPCRequest) error {
+	if req.JsonRPC != "2.0" {
+		return errors.New(`jsonrpc must be "2.0"`)
+	}
+	if req.Method == "" {
+		return errors.New("method is required")
+	}
+	// Absent or null params are left for each method to judge
+	if p := bytes.TrimSpace(req.Params); len(p) > 0 && p[0] != '{' && p[0] != '[' && string(p) != "null" {
+		return errors.New("params must be an object or array")
+	}
+	return nil
+}
+
+// handleRequest dispatches one request. The returned bool is false for
+// notifications, whose response must not be sent.
+//
+// A panic in a handler fails only this request, with -32603.
+//
+// With a DedupCache, a request whose id the session already used gets the
+// earlier reply instead of running again.
+func (s *MCPServer) handleRequest(ctx context.Context, req JSONRPCRequest) (resp JSONRPCResponse, ok bool) {
+	key := dedupKey(ctx, req)
+	if call, first := s.dedup.begin(key, req.Method); call != nil {
+		if !first {
+			if prior, ok := call.wait(ctx); ok {
+				return prior, true
+			}
+			return s.sendError(req.ID, -32800, "Request cancelled", nil), true
+		}
+		// Deferred first so it sees the reply set by the panic handler below
+		defer func() { s.dedup.finish(key, call, resp) }()
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			// Not logged with ctx: the stack must not reach the client
+			slog.Error("Panic handling request", "method", req.Method, "panic", v, "stack", string(debug.Stack()))
+			resp, ok = s.sendError(req.ID, -32603, "Internal error", nil), !isNotification(req)
+		}
+	}()
+
+	start := time.Now()
+	resp = s.dispatch(ctx, req)
+
+	method := req.Method
+	if !knownMethods[method] {
+		method = "unknown"
+	}
+	s.metrics.observeRequest(method, time.Since(start))
+	s.counters.observeRequest(method)
+	return resp, !isNotification(req)
+}
+
+func (s *MCPServer) dispatch(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if err := validateRequest(req); err != nil {
+		return newInvalidRequest(req.ID, err.Error())
+	}
+
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		// The transport doesn't track sessions; state lasts for this message
+		sess = newSession()
+		ctx = withSession(ctx, sess)
+	}
+
 	switch req.Method {
 
 	case "initialize":
-		return s.handleInitialize(req.ID, req.Params)
+		return s.handleInitialize(sess, req.ID, req.Params)
 
+	// Notifications: handleRequest discards whatever they return
 	case "notifications/initialized":
+		s.handleInitializedNotification(sess)
+		return JSONRPCResponse{}
+
+	case "notifications/cancelled":
+		s.handleCancelledNotification(sess, req.Params)
 		return JSONRPCResponse{}
 
 	case "tools/list":
-		if !s.isInitialized() {
+		if !s.isInitialized(sess) {
 			return s.sendError(req.ID, -32002, "Server not initialized", nil)
 		}
-		return s.handleToolsList(req.ID)
+		return s.handleToolsList(req.ID, req.Params)
 
 	case "tools/call":
-		if !s.isInitialized() {
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		if s.limiter != nil {
+			if ok, wait := s.limiter.Allow(rateLimitKey(ctx)); !ok {
+				return s.sendError(req.ID, -32099, "Rate limit exceeded", RateLimitErrorData{
+					RetryAfterSeconds: retryAfterSeconds(wait),
+				})
+			}
+		}
+		return s.handleCallTool(ctx, req.ID, req.Params)
+
+	case "resources/list":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return s.handleResourcesList(req.ID)
+
+	case "resources/read":
+		if !s.isInitialized(sess) {
 			return s.sendError(req.ID, -32002, "Server not initialized", nil)
 		}
-		return s.handleCallTool(req.ID, req.Params)
+		return s.handleResourcesRead(req.ID, req.Params)
 
+	case "resources/templates/list":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return s.handleResourceTemplatesList(req.ID)
+
+	case "prompts/list":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return s.handlePromptsList(req.ID)
+
+	case "prompts/get":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return s.handlePromptsGet(req.ID, req.Params)
+
+	case "completion/complete":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return s.handleComplete(req.ID, req.Params)
+
+	case "logging/setLevel":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return s.handleSetLevel(ctx, req.ID, req.Params)
+
+	case "admin/shutdown":
+		return s.handleAdminShutdown(ctx, req.ID)
+
+	// Same capabilities as initialize reports, recomputed in case providers
+	// were registered since
+	case "capabilities/get":
+		if !s.isInitialized(sess) {
+			return s.sendError(req.ID, -32002, "Server not initialized", nil)
+		}
+		return JSONRPCResponse{
+			JsonRPC: "2.0",
+			ID:      req.ID,
+			Result:  CapabilitiesResult{Capabilities: s.capabilities()},
+		}
+
+	// The ping result is always an empty object. Clients should treat any
+	// non-error reply as proof the server is alive and ignore its contents.
 	case "ping":
 		return JSONRPCResponse{
 			JsonRPC: "2.0",
@@ -167,67 +610,400 @@ func (s *MCPServer) handleRequest(req JSONRPCRequest) JSONRPCResponse {
 		}
 
 	default:
-		return s.sendError(req.ID, -32601, "Method not found", req.Method)
+		if isNotification(req) {
+			// No reply is allowed, so the client can't be told either
+			log.Printf("Ignoring unknown notification %s", req.Method)
+			return JSONRPCResponse{}
+		}
+		return newMethodNotFound(req.ID, req.Method)
 	}
 }
 
-func (s *MCPServer) isInitialized() bool {
+// handleMessage decodes a raw JSON-RPC message, which may be a single request
+// or a batch, and dispatches it. The returned bool is false when nothing
+// should be written back (e.g. a lone notification or a batch of them).
+//
+// ctx is passed down to tool handlers. Over HTTP it is the request's context,
+// carrying the caller's token claims and ending if the client disconnects.
+func (s *MCPServer) handleMessage(ctx context.Context, raw []byte) (interface{}, bool) {
+	raw = bytes.TrimSpace(raw)
+
+	if len(raw) > 0 && raw[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			return s.sendError(nil, -32700, "Parse error", nil), true
+		}
+		if len(batch) == 0 {
+			return newInvalidRequest(nil, "empty batch"), true
+		}
+
+		responses := make([]JSONRPCResponse, 0, len(batch))
+		for _, item := range batch {
+			var req JSONRPCRequest
+			if err := json.Unmarshal(item, &req); err != nil {
+				responses = append(responses, newInvalidRequest(nil, invalidRequestDetail(err)))
+				continue
+			}
+			if resp, ok := s.handleRequest(ctx, req); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil, false
+		}
+		return responses, true
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if errors.Is(err, errInvalidID) {
+			return newInvalidRequest(nil, invalidRequestDetail(err)), true
+		}
+		return s.sendError(nil, -32700, "Parse error", nil), true
+	}
+
+	resp, ok := s.handleRequest(ctx, req)
+	if !ok {
+		return nil, false
+	}
+	return resp, true
+}
+
+// isInitialized reports whether initialize has been answered. Requests are
+// accepted from then on without waiting for notifications/initialized, since
+// clients commonly pipeline their first requests behind it.
+func (s *MCPServer) isInitialized(sess *Session) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.initialized
+	return sess.state != stateNew
+}
+
+// handleInitializedNotification completes the handshake. A notification that
+// arrives before initialize is out of order and ignored.
+func (s *MCPServer) handleInitializedNotification(sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch sess.state {
+	case stateNew:
+		log.Println("Ignoring notifications/initialized received before initialize")
+	case stateInitializing:
+		sess.state = stateReady
+	}
 }
 
-func (s *MCPServer) handleInitialize(id interface{}, params json.RawMessage) JSONRPCResponse {
+type CancelledParams struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// UnmarshalJSON decodes requestId the same way as JSONRPCRequest.ID so it
+// matches the key the call was tracked under.
+func (p *CancelledParams) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Reason    string          `json:"reason,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	id, err := parseRequestID(raw.RequestID)
+	if err != nil {
+		return err
+	}
+	p.RequestID, p.Reason = id, raw.Reason
+	return nil
+}
+
+// trackCall derives a cancellable context for the request id so that a
+// notifications/cancelled on the same session can abort it. The returned
+// func must be called once the request has finished.
+func (s *MCPServer) trackCall(ctx context.Context, id interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Ids decode to string or json.Number; nil ones can't be cancelled
+	switch id.(type) {
+	case string, json.Number:
+	default:
+		return ctx, cancel
+	}
+	sess := sessionFromContext(ctx)
+
+	s.mu.Lock()
+	sess.inflight[id] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(sess.inflight, id)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// handleCancelledNotification aborts the in-flight request the client gave
+// up on. Unknown or already finished ids are ignored, as the spec requires.
+func (s *MCPServer) handleCancelledNotification(sess *Session, params json.RawMessage) {
+	var p CancelledParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		log.Printf("Ignoring malformed notifications/cancelled: %v", err)
+		return
+	}
+
+	s.mu.RLock()
+	cancel, ok := sess.inflight[p.RequestID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	log.Printf("Cancelling request %v: %s", p.RequestID, p.Reason)
+	cancel()
+}
+
+// supportedVersions lists the MCP revisions this server speaks, newest first.
+var supportedVersions = []string{"2025-03-26", "2024-11-05"}
+
+// negotiateProtocolVersion picks the version to answer initialize with. A
+// supported request is echoed back; a newer unknown one gets our latest so
+// the client can decide whether to continue. A missing version, or one older
+// than anything we support, leaves no overlap and is rejected.
+func negotiateProtocolVersion(requested string) (string, bool) {
+	for _, v := range supportedVersions {
+		if v == requested {
+			return v, true
+		}
+	}
+	// Revisions are dates, so they order lexically
+	oldest := supportedVersions[len(supportedVersions)-1]
+	if requested == "" || requested < oldest {
+		return "", false
+	}
+	return supportedVersions[0], true
+}
+
+func (s *MCPServer) handleInitialize(sess *Session, id interface{}, params json.RawMessage) JSONRPCResponse {
 	var initParams InitializeParams
 	if err := json.Unmarshal(params, &initParams); err != nil {
-		return s.sendError(id, -32602, "Invalid params", err.Error())
+		return newInvalidParams(id, err)
+	}
+
+	version, ok := negotiateProtocolVersion(initParams.ProtocolVersion)
+	if !ok {
+		return s.sendError(id, -32602, "Unsupported protocol version", VersionErrorData{
+			Requested: initParams.ProtocolVersion,
+			Supported: supportedVersions,
+		})
 	}
 
+	// A session is initialized once; clients start a new one (a new HTTP
+	// session or connection) to renegotiate. Before notifications/initialized
+	// a repeat is allowed, since the client may have lost the first reply.
 	s.mu.Lock()
-	s.initialized = true
+	if sess.state == stateReady {
+		s.mu.Unlock()
+		return newInvalidRequest(id, "session already initialized; start a new session to initialize again")
+	}
+	sess.state = stateInitializing
+	sess.protocolVersion = version
 	s.mu.Unlock()
 
 	return JSONRPCResponse{
 		JsonRPC: "2.0",
 		ID:      id,
 		Result: InitializeResult{
-			ProtocolVersion: "2024-11-05",
-			Capabilities: ServerCapabilities{
-				Tools: &ToolsCapability{ListChanged: false},
-			},
-			ServerInfo: ServerInfo{
-				Name:    "indian-store-mcp-server",
-				Version: "1.0.0",
-			},
+			ProtocolVersion: version,
+			Capabilities:    s.capabilities(),
+			ServerInfo:      s.info,
 		},
 	}
 }
 
-func (s *MCPServer) handleToolsList(id interface{}) JSONRPCResponse {
+// capabilities reports what this server currently supports.
+func (s *MCPServer) capabilities() ServerCapabilities {
+	caps := ServerCapabilities{
+		Tools:   &ToolsCapability{ListChanged: true},
+		Logging: &LoggingCapability{},
+	}
+	if s.resourceProvider() != nil {
+		caps.Resources = &ResourcesCapability{ListChanged: false}
+	}
+	if s.hasPrompts() {
+		caps.Prompts = &PromptsCapability{ListChanged: false}
+	}
+	if s.completionProvider() != nil {
+		caps.Completions = &CompletionsCapability{}
+	}
+	return caps
+}
+
+func (s *MCPServer) handleToolsList(id interface{}, params json.RawMessage) JSONRPCResponse {
+	offset, err := parseCursor(params)
+	if err != nil {
+		return newInvalidParams(id, err)
+	}
+
+	tools := s.listTools()
+	start, end, next, err := page(offset, len(tools), defaultPageSize)
+	if err != nil {
+		return newInvalidParams(id, err)
+	}
+
 	return JSONRPCResponse{
 		JsonRPC: "2.0",
 		ID:      id,
-		Result: ToolsListResult{
-			Tools: []Tool{
-				{
-					Name:        "list_indian_stores",
-					Description: "List popular Indian online stores",
-					InputSchema: InputSchema{Type: "object"},
-				},
-			},
-		},
+		Result:  ToolsListResult{Tools: tools[start:end], NextCursor: next},
+	}
+}
+
+func (s *MCPServer) listTools() []Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(s.toolOrder))
+	for _, name := range s.toolOrder {
+		if s.toolEnabled(name) {
+			tools = append(tools, s.tools[name].tool)
+		}
 	}
+	return tools
 }
 
-func (s *MCPServer) handleCallTool(id interface{}, params json.RawMessage) JSONRPCResponse {
+func (s *MCPServer) handleCallTool(ctx context.Context, id interface{}, params json.RawMessage) JSONRPCResponse {
+	// Without this, absent params surface as "unexpected end of JSON input"
+	if len(params) == 0 || string(params) == "null" {
+		return s.sendError(id, -32602, "Invalid params", DetailErrorData{Detail: "params must be an object"})
+	}
+	var callParams CallToolParams
+	if err := json.Unmarshal(params, &callParams); err != nil {
+		return newInvalidParams(id, err)
+	}
+	if callParams.Name == "" {
+		return s.sendError(id, -32602, "Invalid params: name is required", nil)
+	}
+
+	rt, ok, enabled := s.lookupTool(callParams.Name)
+	if !ok {
+		return s.sendError(id, -32602, "Unknown tool: "+callParams.Name, NotFoundErrorData{Name: callParams.Name})
+	}
+	if !enabled {
+		return s.sendError(id, -32601, "Tool disabled: "+callParams.Name, MethodErrorData{Method: callParams.Name})
+	}
+
+	// Without claims auth is disabled (or we're on stdio), so scopes don't apply
+	if claims, ok := claimsFromContext(ctx); ok {
+		if missing := claims.missingScopes(rt.requiredScopes); len(missing) > 0 {
+			return s.sendError(id, -32003, "Insufficient scope for tool "+callParams.Name, ScopeErrorData{
+				Required: rt.requiredScopes,
+				Missing:  missing,
+			})
+		}
+	}
+
+	if errs := validateArguments(rt.tool.InputSchema, rt.patterns, callParams.Arguments); len(errs) > 0 {
+		return newValidationError(id, argumentErrorMessage(callParams.Name, errs), errs)
+	}
+
+	if callParams.ValidateOnly {
+		return JSONRPCResponse{
+			JsonRPC: "2.0",
+			ID:      id,
+			Result: CallToolResult{
+				Content: []Content{{Type: "text", Text: "Arguments are valid for tool " + callParams.Name}},
+			},
+		}
+	}
+
+	var cacheKey string
+	if rt.tool.Cacheable {
+		if key, ok := resultCacheKey(callParams.Name, callParams.Arguments); ok {
+			if result, hit := s.cache.Get(key); hit {
+				slog.DebugContext(ctx, "Serving cached tool result", "tool", callParams.Name)
+				return JSONRPCResponse{JsonRPC: "2.0", ID: id, Result: s.sanitizeResult(ctx, callParams.Name, result)}
+			}
+			cacheKey = key
+		}
+	}
+
+	ctx, done := s.trackCall(ctx, id)
+	defer done()
+	if s.toolTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.toolTimeout)
+		defer cancel()
+	}
+	if token := callParams.Meta["progressToken"]; token != nil {
+		ctx = withProgressToken(ctx, token)
+	}
+
+	ctx, span := tracer().Start(ctx, "tools/call "+callParams.Name,
+		trace.WithAttributes(attribute.String("mcp.tool.name", callParams.Name)))
+	defer span.End()
+
+	slog.DebugContext(ctx, "Calling tool", "tool", callParams.Name)
+	start := time.Now()
+	result, err := rt.handler(ctx, callParams.Arguments)
+	elapsed := time.Since(start)
+	s.metrics.observeToolCall(callParams.Name, err != nil || result.IsError)
+	if s.slowTool > 0 && elapsed > s.slowTool {
+		slog.WarnContext(ctx, "Slow tool call", "tool", callParams.Name, "duration", elapsed, "session", sessionFromContext(ctx).ID)
+	}
+
+	entry := AuditEntry{
+		Time:       start,
+		Tool:       callParams.Name,
+		Arguments:  summarizeArgs(callParams.Arguments),
+		Outcome:    "success",
+		DurationMS: float64(elapsed.Microseconds()) / 1000,
+	}
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	switch {
+	case ctx.Err() == context.Canceled:
+		entry.Outcome = "cancelled"
+	case timedOut:
+		entry.Outcome = "timeout"
+	case err != nil:
+		entry.Outcome, entry.Error = "error", err.Error()
+	case result.IsError:
+		entry.Outcome = "error"
+	}
+	s.audit.Record(entry)
+	span.SetAttributes(attribute.String("mcp.tool.outcome", entry.Outcome))
+	if entry.Outcome != "success" {
+		span.SetStatus(codes.Error, entry.Outcome)
+	}
+
+	if ctx.Err() == context.Canceled {
+		return s.sendError(id, -32800, "Request cancelled", nil)
+	}
+	if timedOut {
+		slog.WarnContext(ctx, "Tool timed out", "tool", callParams.Name, "timeout", s.toolTimeout)
+		return JSONRPCResponse{
+			JsonRPC: "2.0",
+			ID:      id,
+			Result: CallToolResult{
+				Content: []Content{{Type: "text", Text: fmt.Sprintf("Tool %s timed out after %s", callParams.Name, s.toolTimeout)}},
+				IsError: true,
+			},
+		}
+	}
+	if cacheKey != "" && err == nil && !result.IsError {
+		s.cache.Put(cacheKey, result)
+	}
+	// A failing tool is a result the model can see and react to, not a
+	// protocol error; those are reserved for requests we couldn't run at all
+	if err != nil {
+		slog.ErrorContext(ctx, "Tool failed", "tool", callParams.Name, "error", err)
+		result = CallToolResult{
+			Content: []Content{{Type: "text", Text: "Tool execution failed: " + err.Error()}},
+			IsError: true,
+		}
+	}
+
 	return JSONRPCResponse{
 		JsonRPC: "2.0",
 		ID:      id,
-		Result: CallToolResult{
-			Content: []Content{
-				{Type: "text", Text: "Flipkart, Amazon India, Reliance Digital, Myntra, Snapdeal, Tata CLiQ"},
-			},
-		},
+		Result:  s.sanitizeResult(ctx, callParams.Name, result),
 	}
 }
 
@@ -239,35 +1015,265 @@ func (s *MCPServer) handleCallTool(id interface{}, params json.RawMessage) JSONR
 
 func (s *MCPServer) handleMCPRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	// Clients end their session explicitly with a DELETE
+	if r.Method == http.MethodDelete {
+		if !s.removeSession(r.Header.Get("Mcp-Session-Id")) {
+			http.Error(w, "Unknown session", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req JSONRPCRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		json.NewEncoder(w).Encode(JSONRPCResponse{
-			JsonRPC: "2.0",
-			Error:   &RPCError{Code: -32700, Message: "Parse error"},
-		})
+	info := requestInfoFromContext(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeRPC(w, info, http.StatusRequestEntityTooLarge, s.sendError(nil, -32600, "Request body too large",
+				DetailErrorData{Detail: fmt.Sprintf("limit is %d bytes", tooLarge.Limit)}))
+			return
+		}
+		writeRPC(w, info, http.StatusBadRequest, s.sendError(nil, -32700, "Parse error", DetailErrorData{Detail: err.Error()}))
 		return
 	}
 
-	json.NewEncoder(w).Encode(s.handleRequest(req))
+	if info != nil {
+		info.RPCMethod = rpcMethodOf(body)
+	}
+
+	// Without a known Mcp-Session-Id only initialize can succeed; it starts
+	// a new session whose ID we hand back
+	sess, known := s.lookupSession(r.Header.Get("Mcp-Session-Id"))
+	if !known {
+		sess = newSession()
+	}
+
+	ctx := withClientAddr(withSession(r.Context(), sess), r)
+	resp, ok := s.handleMessage(ctx, body)
+	if !known && s.isInitialized(sess) {
+		s.addSession(sess)
+		w.Header().Set("Mcp-Session-Id", sess.ID)
+	}
+	if !ok {
+		// Notifications get no JSON-RPC reply, not even an empty object
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	setRetryAfter(w, resp)
+	writeRPC(w, info, httpStatusFor(resp), resp)
 }
 
-func healthCheck(w http.ResponseWriter, _ *http.Request) {
+// handleToolsREST serves the tool registry as a plain JSON array, the same
+// tools tools/list returns, for dashboards that don't speak MCP.
+func (s *MCPServer) handleToolsREST(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(s.listTools())
+}
+
+func writeRPC(w http.ResponseWriter, info *requestInfo, status int, resp interface{}) {
+	resp = annotateErrors(resp, info)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// httpStatusFor picks the HTTP status for a JSON-RPC reply.
+//
+// Only failures to understand the message as a whole map to an HTTP error:
+//
+//	-32700 Parse error      -> 400 Bad Request (body is not valid JSON)
+//	-32600 Invalid Request  -> 400 Bad Request (e.g. an empty batch)
+//
+// Every other RPC error (method not found, invalid params, not initialized,
+// ...) is an application-level result of a well-formed call and is sent with
+// 200 so HTTP-aware proxies don't treat it as a transport failure. Batch
+// replies are always 200 since individual entries may succeed.
+func httpStatusFor(resp interface{}) int {
+	single, ok := resp.(JSONRPCResponse)
+	if !ok || single.Error == nil || single.ID != nil {
+		return http.StatusOK
+	}
+	switch single.Error.Code {
+	case -32700, -32600:
+		return http.StatusBadRequest
+	default:
+		return http.StatusOK
+	}
+}
+
+// recoverPanics answers with a JSON-RPC internal error instead of dropping
+// the connection if next panics outside of request dispatch.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			slog.Error("Panic serving HTTP request", "path", r.URL.Path, "panic", v, "stack", string(debug.Stack()))
+			w.Header().Set("Content-Type", "application/json")
+			writeRPC(w, requestInfoFromContext(r.Context()), http.StatusInternalServerError,
+				JSONRPCResponse{JsonRPC: "2.0", Error: &RPCError{Code: -32603, Message: "Internal error"}})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitBody caps the size of request bodies read by next.
+func limitBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitConcurrency lets at most n requests into next at once. The rest are
+// turned away with 503 and Retry-After instead of queueing, so a load spike
+// can't pile up goroutines and memory. n <= 0 means no limit.
+func limitConcurrency(n int, next http.Handler) http.Handler {
+	if n <= 0 {
+		return next
+	}
+	slots := make(chan struct{}, n)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set("Content-Type", "application/json")
+			writeRPC(w, requestInfoFromContext(r.Context()), http.StatusServiceUnavailable,
+				newRPCError(nil, -32099, "Server busy", RateLimitErrorData{RetryAfterSeconds: 1}))
+		}
+	})
+}
+
+// toolWriteMargin is the time allowed past -tool-timeout to write the
+// reply to a tool call that was cut off.
+const toolWriteMargin = 5 * time.Second
+
+// extendWriteDeadline gives each request at least d to write its reply,
+// counted from when next starts. The server's WriteTimeout runs from the
+// request headers, so without this a tool timeout close to it would be
+// reported to nobody.
+func extendWriteDeadline(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// healthProbeTimeout bounds the Casdoor probe of /health?deep=1.
+const healthProbeTimeout = 3 * time.Second
+
+// healthCheck is a liveness probe: it answers as soon as the process serves
+// HTTP at all. With ?deep=1 it also fetches Casdoor's JWKS, answering 503
+// "degraded" when that fails; jwksURI may be empty if Casdoor isn't
+// configured.
+func healthCheck(jwksURI string, client *http.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := map[string]string{"status": "ok"}
+		code := http.StatusOK
+
+		if deep, _ := strconv.ParseBool(r.URL.Query().Get("deep")); deep && jwksURI != "" {
+			if err := probeCasdoor(r.Context(), client, jwksURI); err != nil {
+				log.Printf("health: Casdoor unreachable: %v", err)
+				status = map[string]string{"status": "degraded", "casdoor": "unreachable"}
+				code = http.StatusServiceUnavailable
+			} else {
+				status["casdoor"] = "ok"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+func probeCasdoor(ctx context.Context, client *http.Client, jwksURI string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// readiness backs /readyz. Unlike /health it reports 503 until startup work,
+// such as fetching Casdoor's signing keys, has finished.
+type readiness struct {
+	ready atomic.Bool
+}
+
+func (rd *readiness) markReady() {
+	rd.ready.Store(true)
+}
+
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !rd.ready.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "starting"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
+
+// warmUpRetry is how long to wait between failed warm-up attempts.
+const warmUpRetry = 5 * time.Second
+
+// warmUp primes validators that fetch state from Casdoor, retrying until it
+// succeeds or ctx is cancelled, so the first authenticated request isn't the
+// one that pays for it.
+func warmUp(ctx context.Context, v TokenValidator) {
+	w, ok := v.(interface{ Warm() error })
+	if !ok {
+		return
+	}
+	for {
+		err := w.Warm()
+		if err == nil {
+			return
+		}
+		log.Printf("warm-up: %v (retrying in %s)", err, warmUpRetry)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmUpRetry):
+		}
+	}
 }
 
 //
@@ -276,39 +1282,126 @@ func healthCheck(w http.ResponseWriter, _ *http.Request) {
 // --------------------
 //
 
-func oauthAuthorizationServerHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+func oauthAuthorizationServerHandler(endpoints *CasdoorEndpoints) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if endpoints == nil {
+			http.Error(w, "Casdoor endpoint not configured", http.StatusInternalServerError)
+			return
+		}
+
+		metadata := map[string]interface{}{
+			"issuer":                   endpoints.Issuer,
+			"authorization_endpoint":   endpoints.AuthorizationEndpoint,
+			"token_endpoint":           endpoints.TokenEndpoint,
+			"jwks_uri":                 endpoints.JWKSURI,
+			"response_types_supported": []string{"code"},
+			"grant_types_supported":    []string{"authorization_code", "refresh_token"},
+			"scopes_supported":         endpoints.Scopes,
+			"subject_types_supported":  []string{"public"},
+			// Casdoor supports PKCE, which public MCP clients rely on
+			"code_challenge_methods_supported": []string{"S256"},
+			"response_modes_supported":         []string{"query"},
+		}
+		if endpoints.UserinfoEndpoint != "" {
+			metadata["userinfo_endpoint"] = endpoints.UserinfoEndpoint
+		}
+		if endpoints.IntrospectionEndpoint != "" {
+			metadata["introspection_endpoint"] = endpoints.IntrospectionEndpoint
+		}
+
+		writeMetadata(w, r, metadata)
 	}
+}
 
-	issuer := os.Getenv("OAUTH_ISSUER")
-	authz := os.Getenv("OAUTH_AUTHORIZATION_ENDPOINT")
-	token := os.Getenv("OAUTH_TOKEN_ENDPOINT")
-	jwks := os.Getenv("OAUTH_JWKS_URI")
-	scopes := os.Getenv("OAUTH_SCOPES")
+// protectedResourceHandler publishes RFC 9728 protected-resource metadata so
+// MCP clients can find the authorization server before calling /mcp.
+func protectedResourceHandler(endpoints *CasdoorEndpoints, resourceURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	if issuer == "" || authz == "" || token == "" || jwks == "" {
-		http.Error(w, "OIDC env vars not set", http.StatusInternalServerError)
-		return
+		if endpoints == nil {
+			http.Error(w, "Casdoor endpoint not configured", http.StatusInternalServerError)
+			return
+		}
+
+		resource := resourceURL
+		if resource == "" {
+			resource = externalBaseURL(r) + "/mcp"
+			w.Header().Add("Vary", "Host, X-Forwarded-Host, X-Forwarded-Proto")
+		}
+
+		metadata := map[string]interface{}{
+			"resource":                 resource,
+			"authorization_servers":    []string{endpoints.Issuer},
+			"scopes_supported":         endpoints.Scopes,
+			"bearer_methods_supported": []string{"header"},
+		}
+
+		writeMetadata(w, r, metadata)
 	}
+}
+
+// metadataMaxAge is how long clients and proxies may cache discovery
+// metadata, which only changes on restart.
+const metadataMaxAge = time.Hour
 
-	metadata := map[string]interface{}{
-		"issuer":                 issuer,
-		"authorization_endpoint": authz,
-		"token_endpoint":         token,
-		"jwks_uri":               jwks,
-		"response_types_supported": []string{"code"},
-		"grant_types_supported":    []string{"authorization_code", "refresh_token"},
-		"scopes_supported":         strings.Split(scopes, " "),
-		"subject_types_supported":  []string{"public"},
+// writeMetadata serves discovery metadata with caching headers. The ETag is
+// a hash of the body, so a client polling with If-None-Match gets 304 Not
+// Modified until the metadata changes.
+func writeMetadata(w http.ResponseWriter, r *http.Request, metadata interface{}) {
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
 
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(metadataMaxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	json.NewEncoder(w).Encode(metadata)
+	w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for GET.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// externalBaseURL reconstructs the scheme and host the client used to reach
+// us, honouring the headers set by the gateway in front of the server.
+func externalBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host
 }
 
 //
@@ -318,14 +1411,216 @@ func oauthAuthorizationServerHandler(w http.ResponseWriter, r *http.Request) {
 //
 
 func main() {
+	cfg, err := parseConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+
+	if err := setupLogging(cfg.LogFormat); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	endpoints, err := cfg.casdoorEndpoints()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	catalog, err := loadCatalog(cfg.CatalogPath, cfg.CatalogExpandEnv, cfg.CatalogFallback)
+	if err != nil {
+		log.Fatalf("catalog: %v", err)
+	}
+
 	server := NewMCPServer()
+	server.SetName(cfg.ServerName)
+	server.SetToolTimeout(cfg.ToolTimeout)
+	server.SetSlowToolThreshold(time.Duration(cfg.SlowToolMS) * time.Millisecond)
+	if err := server.SetSanitizeMode(cfg.SanitizeOutput); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if cfg.ToolCacheTTL > 0 {
+		server.SetResultCache(NewResultCache(cfg.ToolCacheTTL))
+	}
+	if cfg.DedupTTL > 0 {
+		server.SetDedupCache(NewDedupCache(cfg.DedupTTL))
+	}
+	// One client for every outbound call, so they share a connection pool
+	outbound := cfg.casdoorClient()
+	if err := registerStoreTools(server, catalog, outbound, cfg.StoreCheckTimeout, StubDeliveryChecker{}); err != nil {
+		log.Fatalf("register tools: %v", err)
+	}
+	if unknown := server.RestrictTools(cfg.EnabledTools, cfg.DisabledTools); len(unknown) > 0 {
+		log.Printf("config: ignoring unknown tools in -enabled-tools/-disabled-tools: %s", strings.Join(unknown, ", "))
+	}
+	if err := registerStorePrompts(server, catalog); err != nil {
+		log.Fatalf("register prompts: %v", err)
+	}
+	server.RegisterResources(catalog)
+	server.RegisterCompletions(catalog)
+
+	shutdownTracing, err := setupTracing(context.Background(), cfg.OTLPEndpoint, cfg.ServerName)
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+	flushTraces := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("tracing: flushing spans: %v", err)
+		}
+	}
+
+	switch cfg.Transport {
+	case "stdio":
+		// stdout carries the protocol; setupLogging already writes to stderr
+		err := server.serveStdio(os.Stdin, os.Stdout, cfg.PingInterval)
+		flushTraces()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "http":
+	default:
+		log.Fatalf("unknown transport %q (want http or stdio)", cfg.Transport)
+	}
+
+	var validator TokenValidator
+	protect := func(h http.Handler) http.Handler { return h }
+	if cfg.RequireAuth {
+		validator, err = cfg.tokenValidator(endpoints, outbound)
+		if err != nil {
+			log.Fatalf("config: %v", err)
+		}
+		protect = NewAuthenticator(validator).middleware
+	}
+	mcpHandler := checkSessionID(limitConcurrency(cfg.MaxConcurrency, protect(limitBody(cfg.MaxBodyBytes, recoverPanics(http.HandlerFunc(server.handleMCPRequest))))))
+	if cfg.ToolTimeout > 0 && cfg.WriteTimeout > 0 {
+		if cfg.ToolTimeout >= cfg.WriteTimeout {
+			log.Printf("config: -tool-timeout %s is not shorter than -write-timeout %s; /mcp replies get %s to write so tool timeouts still reach clients",
+				cfg.ToolTimeout, cfg.WriteTimeout, cfg.ToolTimeout+toolWriteMargin)
+		}
+		mcpHandler = extendWriteDeadline(max(cfg.WriteTimeout, cfg.ToolTimeout+toolWriteMargin), mcpHandler)
+	}
+	sse := NewSSEHub(server)
 
-	http.HandleFunc("/mcp", server.handleMCPRequest)
-	http.HandleFunc("/health", healthCheck)
+	if cfg.RateLimit > 0 {
+		limiter := NewRateLimiter(cfg.RateLimit, cfg.RateBurst)
+		server.SetRateLimiter(limiter)
+		go func() {
+			for range time.Tick(time.Minute) {
+				limiter.prune(10 * time.Minute)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	if cfg.Metrics {
+		metrics := NewMetrics()
+		server.SetMetrics(metrics)
+		mux.Handle("/metrics", metrics.Handler())
+	}
+
+	cors := NewCORSPolicy(cfg.CORSOrigins, cfg.CORSMethods, cfg.CORSHeaders, cfg.CORSMaxAge)
+
+	// Only a server on localhost is exposed to DNS rebinding
+	originGuard := NewOriginGuard(cfg.AllowedOrigins)
+	guardOrigin := func(h http.Handler) http.Handler { return h }
+	if isLoopbackAddr(cfg.Addr) {
+		guardOrigin = originGuard.wrap
+	}
+
+	mux.Handle("/mcp", logRequests(traceRequests(guardOrigin(cors.wrap(gzipResponses(mcpHandler))))))
+	mux.Handle("/mcp/ws", logRequests(guardOrigin(protect(server.webSocketHandler(wsOriginCheck(originGuard, cors), cfg.MaxBodyBytes)))))
+	mux.Handle("/mcp/sse", logRequests(guardOrigin(cors.wrap(protect(http.HandlerFunc(sse.handleStream))))))
+	mux.Handle("/mcp/sse/message", logRequests(guardOrigin(cors.wrap(protect(limitBody(cfg.MaxBodyBytes, http.HandlerFunc(sse.handleMessage)))))))
+	var jwksURI string
+	if endpoints != nil {
+		jwksURI = endpoints.JWKSURI
+	}
+	mux.Handle("/health", healthCheck(jwksURI, outbound))
+	mux.Handle("/version", versionHandler(cfg.ServerName))
+	mux.Handle("/stats", http.HandlerFunc(server.handleStats))
+	mux.Handle("/openapi.json", cors.wrap(openAPIHandler(cfg.ServerName)))
+	if cfg.Debug {
+		audit := NewAuditLog(auditLogSize)
+		server.SetAuditLog(audit)
+		mux.Handle("/debug/audit", protect(audit.Handler()))
+		mux.Handle("/tools", protect(http.HandlerFunc(server.handleToolsREST)))
+	}
+	ready := &readiness{}
+	mux.Handle("/readyz", ready)
 
 	// ✅ OAuth discovery pointing to CASDOOR
-	http.HandleFunc("/.well-known/oauth-authorization-server", oauthAuthorizationServerHandler)
+	mux.Handle("/.well-known/oauth-authorization-server", cors.wrap(oauthAuthorizationServerHandler(endpoints)))
+	mux.Handle("/.well-known/oauth-protected-resource", cors.wrap(protectedResourceHandler(endpoints, cfg.ResourceURL)))
+
+	httpServer := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		TLSConfig:         tlsConfig,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.RequireAuth {
+		server.EnableAdmin(cfg.AdminScope, stop)
+	}
 
-	log.Println("MCP server running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if cfg.SessionTTL > 0 {
+		go server.reapSessions(ctx, cfg.SessionTTL)
+	}
+
+	go func() {
+		warmUp(ctx, validator)
+		ready.markReady()
+	}()
+
+	err = runHTTPServer(ctx, httpServer, cfg.ShutdownTimeout)
+	flushTraces()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runHTTPServer serves until ctx is cancelled, then gives in-flight requests
+// up to grace to finish before returning.
+func runHTTPServer(ctx context.Context, srv *http.Server, grace time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			// The certificate is already in TLSConfig
+			log.Printf("MCP server running on %s (TLS)", srv.Addr)
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		log.Printf("MCP server running on %s", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down (grace period %s)", grace)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	log.Println("Shutdown complete")
+	return nil
 }
@@ -0,0 +1,1301 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// call sends one request through handleMessage on ctx's session.
+func call(t *testing.T, s *MCPServer, ctx context.Context, method string, params interface{}) JSONRPCResponse {
+	t.Helper()
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	raw, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := s.handleMessage(ctx, raw)
+	if !ok {
+		t.Fatalf("%s: no reply", method)
+	}
+	return resp.(JSONRPCResponse)
+}
+
+// initialized returns a context carrying a session that has completed the
+// initialize handshake with s.
+func initialized(t *testing.T, s *MCPServer) context.Context {
+	t.Helper()
+	ctx := withSession(context.Background(), newSession())
+	resp := call(t, s, ctx, "initialize", map[string]interface{}{"protocolVersion": supportedVersions[0]})
+	if resp.Error != nil {
+		t.Fatalf("initialize: %+v", resp.Error)
+	}
+	s.handleInitializedNotification(sessionFromContext(ctx))
+	return ctx
+}
+
+// toolResult returns the CallToolResult of a successful tools/call reply.
+func toolResult(t *testing.T, resp JSONRPCResponse) CallToolResult {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("tools/call: %+v", resp.Error)
+	}
+	result, ok := resp.Result.(CallToolResult)
+	if !ok {
+		t.Fatalf("result is %T, want CallToolResult", resp.Result)
+	}
+	return result
+}
+
+// registerSlowTool adds a tool named "slow" that blocks until its context
+// ends.
+func registerSlowTool(t *testing.T, s *MCPServer) {
+	t.Helper()
+	err := s.RegisterTool(Tool{Name: "slow", InputSchema: InputSchema{Type: "object"}},
+		func(ctx context.Context, _ map[string]interface{}) (CallToolResult, error) {
+			<-ctx.Done()
+			return CallToolResult{}, ctx.Err()
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToolTimeout(t *testing.T) {
+	s := NewMCPServer()
+	s.SetToolTimeout(20 * time.Millisecond)
+	registerSlowTool(t, s)
+
+	start := time.Now()
+	result := toolResult(t, call(t, s, initialized(t, s), "tools/call", map[string]interface{}{"name": "slow"}))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call took %s, want it cut off near 20ms", elapsed)
+	}
+	if !result.IsError || len(result.Content) == 0 || !strings.Contains(result.Content[0].Text, "timed out") {
+		t.Errorf("result = %+v, want a timed out error result", result)
+	}
+}
+
+// A tool timeout as long as the server's WriteTimeout must still be
+// reported over HTTP.
+func TestToolTimeoutReachesHTTPClient(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	s := NewMCPServer()
+	s.SetToolTimeout(timeout)
+	registerSlowTool(t, s)
+
+	srv := httptest.NewUnstartedServer(extendWriteDeadline(timeout+toolWriteMargin, http.HandlerFunc(s.handleMCPRequest)))
+	srv.Config.WriteTimeout = timeout
+	srv.Start()
+	defer srv.Close()
+
+	post := func(sessionID, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sessionID != "" {
+			req.Header.Set("Mcp-Session-Id", sessionID)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", body, err)
+		}
+		return resp
+	}
+
+	init := post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	init.Body.Close()
+	sessionID := init.Header.Get("Mcp-Session-Id")
+	if sessionID == "" {
+		t.Fatal("initialize returned no Mcp-Session-Id")
+	}
+
+	resp := post(sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slow"}}`)
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+	if !strings.Contains(buf.String(), "timed out") {
+		t.Errorf("reply = %s, want the timeout result", buf.String())
+	}
+}
+
+// A tool call runs on its HTTP request's context, so a client hanging up
+// stops the tool instead of leaving it running.
+func TestToolCallFollowsRequestContext(t *testing.T) {
+	s := NewMCPServer()
+	registerSlowTool(t, s)
+	sessionID := postMCP(s, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`).Header().Get("Mcp-Session-Id")
+	postMCP(s, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"slow"}}`)).WithContext(ctx)
+	req.Header.Set("Mcp-Session-Id", sessionID)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.handleMCPRequest(httptest.NewRecorder(), req)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool call kept running after its request was cancelled")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer jwks.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	client := (&Config{CasdoorTimeout: time.Second, CasdoorMaxAttempts: 1}).casdoorClient()
+	tests := []struct {
+		name     string
+		jwksURI  string
+		query    string
+		wantCode int
+		want     map[string]string
+	}{
+		{"shallow", closed.URL, "", http.StatusOK, map[string]string{"status": "ok"}},
+		{"deep without Casdoor", "", "?deep=1", http.StatusOK, map[string]string{"status": "ok"}},
+		{"deep reachable", jwks.URL + "/jwks", "?deep=1", http.StatusOK, map[string]string{"status": "ok", "casdoor": "ok"}},
+		{"deep error status", jwks.URL + "/broken", "?deep=1", http.StatusServiceUnavailable, map[string]string{"status": "degraded", "casdoor": "unreachable"}},
+		{"deep unreachable", closed.URL, "?deep=1", http.StatusServiceUnavailable, map[string]string{"status": "degraded", "casdoor": "unreachable"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			healthCheck(tt.jwksURI, client)(rec, httptest.NewRequest(http.MethodGet, "/health"+tt.query, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	const n = 2
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := limitConcurrency(n, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	done := make(chan int, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+			done <- rec.Code
+		}()
+		<-entered
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("request %d: status = %d, want 503", n+1, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var reply map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	if id, ok := reply["id"]; !ok || string(id) != "null" || reply["error"] == nil {
+		t.Errorf("body = %s, want a JSON-RPC error with id null", rec.Body)
+	}
+
+	close(release)
+	for i := 0; i < n; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("admitted request: status = %d, want 200", code)
+		}
+	}
+
+	// Slots are freed once requests finish
+	go func() { <-entered }()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after release: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestToolJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		tool Tool
+		want string
+	}{
+		{
+			name: "required properties",
+			tool: Tool{
+				Name:        "get_store_details",
+				Description: "Details",
+				InputSchema: InputSchema{
+					Type:       "object",
+					Properties: map[string]Property{"name": {Type: "string", Description: "Store name"}},
+					Required:   []string{"name"},
+				},
+				Cacheable: true,
+			},
+			want: `{"name":"get_store_details","description":"Details","inputSchema":{"type":"object","properties":{"name":{"type":"string","description":"Store name"}},"required":["name"]}}`,
+		},
+		{
+			name: "no arguments",
+			tool: Tool{Name: "list", Description: "List", InputSchema: InputSchema{Type: "object"}},
+			want: `{"name":"list","description":"List","inputSchema":{"type":"object"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.tool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterToolRequiresDeclaredProperties(t *testing.T) {
+	err := NewMCPServer().RegisterTool(Tool{
+		Name:        "t",
+		InputSchema: InputSchema{Type: "object", Required: []string{"name"}},
+	}, func(context.Context, map[string]interface{}) (CallToolResult, error) { return CallToolResult{}, nil })
+	if err == nil {
+		t.Error("RegisterTool accepted a required property that isn't declared")
+	}
+}
+
+// A client that stops sending its body part way is cut off by the server's
+// ReadTimeout instead of holding the connection open.
+func TestSlowBodyCutOff(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	s := NewMCPServer()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(s.handleMCPRequest))
+	srv.Config.ReadTimeout = timeout
+	srv.Start()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"jsonrpc\":")
+
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("server waited %s for the body, want it cut off near %s", elapsed, timeout)
+	}
+	if err != nil {
+		// Closing the connection without a reply is also a cut off
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	const limit = 64
+	handler := limitBody(limit, http.HandlerFunc(NewMCPServer().handleMCPRequest))
+	small := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"under limit", small, http.StatusOK},
+		{"over limit", small + strings.Repeat(" ", limit), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var reply JSONRPCResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			if reply.Error == nil || reply.Error.Code != -32600 {
+				t.Errorf("error = %+v, want -32600", reply.Error)
+			}
+		})
+	}
+}
+
+func TestHTTPStatusFor(t *testing.T) {
+	ok := JSONRPCResponse{JsonRPC: "2.0", ID: 1, Result: map[string]string{}}
+	tests := []struct {
+		name string
+		resp interface{}
+		want int
+	}{
+		{"result", ok, http.StatusOK},
+		{"parse error", newRPCError(nil, -32700, "Parse error", nil), http.StatusBadRequest},
+		{"invalid request", newInvalidRequest(nil, "empty batch"), http.StatusBadRequest},
+		{"invalid request with id", newInvalidRequest(1, ""), http.StatusOK},
+		{"method not found", newMethodNotFound(1, "nope"), http.StatusOK},
+		{"invalid params", newInvalidParams(1, errors.New("bad")), http.StatusOK},
+		{"batch", []JSONRPCResponse{ok, newRPCError(nil, -32700, "Parse error", nil)}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := httpStatusFor(tt.resp); got != tt.want {
+				t.Errorf("httpStatusFor = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// message sends raw through handleMessage and returns its single reply.
+func message(t *testing.T, s *MCPServer, ctx context.Context, raw string) JSONRPCResponse {
+	t.Helper()
+	resp, ok := s.handleMessage(ctx, []byte(raw))
+	if !ok {
+		t.Fatalf("%s: no reply", raw)
+	}
+	return resp.(JSONRPCResponse)
+}
+
+func TestJSONRPCVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		code int
+	}{
+		{"2.0", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, 0},
+		{"missing", `{"id":1,"method":"ping"}`, -32600},
+		{"1.0", `{"jsonrpc":"1.0","id":1,"method":"ping"}`, -32600},
+		{"empty", `{"jsonrpc":"","id":1,"method":"ping"}`, -32600},
+	}
+	s := NewMCPServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := message(t, s, context.Background(), tt.raw)
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.code {
+				t.Errorf("code = %d, want %d", code, tt.code)
+			}
+			if resp.ID != json.Number("1") {
+				t.Errorf("id = %v, want the request's", resp.ID)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		detail string
+	}{
+		{"valid", `{"jsonrpc":"2.0","id":1,"method":"ping","params":{}}`, ""},
+		{"array params", `{"jsonrpc":"2.0","id":1,"method":"ping","params":[]}`, ""},
+		{"null params", `{"jsonrpc":"2.0","id":1,"method":"ping","params":null}`, ""},
+		{"empty method", `{"jsonrpc":"2.0","id":1,"method":""}`, "method is required"},
+		{"missing method", `{"jsonrpc":"2.0","id":1}`, "method is required"},
+		{"bad version", `{"jsonrpc":"2.1","id":1,"method":"ping"}`, `jsonrpc must be "2.0"`},
+		{"string params", `{"jsonrpc":"2.0","id":1,"method":"ping","params":"x"}`, "params must be an object or array"},
+		{"number params", `{"jsonrpc":"2.0","id":1,"method":"ping","params":42}`, "params must be an object or array"},
+		{"bool params", `{"jsonrpc":"2.0","id":1,"method":"ping","params":true}`, "params must be an object or array"},
+	}
+	s := NewMCPServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := message(t, s, context.Background(), tt.raw)
+			if tt.detail == "" {
+				if resp.Error != nil {
+					t.Fatalf("unexpected error %+v", resp.Error)
+				}
+				return
+			}
+			if resp.Error == nil || resp.Error.Code != -32600 {
+				t.Fatalf("error = %+v, want -32600", resp.Error)
+			}
+			if data, _ := resp.Error.Data.(DetailErrorData); data.Detail != tt.detail {
+				t.Errorf("detail = %+v, want %q", resp.Error.Data, tt.detail)
+			}
+		})
+	}
+}
+
+func TestInitializeOrder(t *testing.T) {
+	const (
+		initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+		notify     = `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+		list       = `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+		callTool   = `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo"}}`
+	)
+	tests := []struct {
+		name  string
+		steps []string
+		last  string
+		code  int
+	}{
+		{"list first", nil, list, -32002},
+		{"call first", nil, callTool, -32002},
+		{"notification without initialize", []string{notify}, list, -32002},
+		{"initialize only", []string{initialize}, list, 0},
+		{"full handshake", []string{initialize, notify}, list, 0},
+		{"early notification then handshake", []string{notify, initialize, notify}, list, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			ctx := withSession(context.Background(), newSession())
+			for _, step := range tt.steps {
+				resp, ok := s.handleMessage(ctx, []byte(step))
+				if step == notify && ok {
+					t.Fatalf("notification got a reply: %+v", resp)
+				}
+			}
+			resp := message(t, s, ctx, tt.last)
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.code {
+				t.Errorf("code = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestDoubleInitialize(t *testing.T) {
+	const (
+		initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+		notify     = `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	)
+	tests := []struct {
+		name       string
+		steps      []string
+		newSession bool // the last initialize starts a new session
+		code       int
+	}{
+		{"first initialize", nil, false, 0},
+		{"repeat before notifications/initialized", []string{initialize}, false, 0},
+		{"repeat after handshake", []string{initialize, notify}, false, -32600},
+		{"new session after handshake", []string{initialize, notify}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			ctx := withSession(context.Background(), newSession())
+			for _, step := range tt.steps {
+				s.handleMessage(ctx, []byte(step))
+			}
+			if tt.newSession {
+				ctx = withSession(context.Background(), newSession())
+			}
+			resp := message(t, s, ctx, initialize)
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.code {
+				t.Fatalf("code = %d, want %d", code, tt.code)
+			}
+			// A rejected repeat leaves the session usable
+			if list := message(t, s, ctx, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); list.Error != nil {
+				t.Errorf("tools/list after initialize: %+v", list.Error)
+			}
+		})
+	}
+}
+
+func TestContentJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content Content
+		want    string
+	}{
+		{"text", Content{Type: "text", Text: "hi"}, `{"type":"text","text":"hi"}`},
+		{"empty text", Content{Type: "text"}, `{"type":"text","text":""}`},
+		{"image", imageContent([]byte("\x89PNG"), "image/png"), `{"type":"image","data":"iVBORw==","mimeType":"image/png"}`},
+		{
+			"resource",
+			Content{Type: "resource", Resource: &ResourceContents{URI: "store://Flipkart", MimeType: "application/json", Text: "{}"}},
+			`{"type":"resource","resource":{"uri":"store://Flipkart","mimeType":"application/json","text":"{}"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelToolCall(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		cancelled bool
+	}{
+		{"matching id", `7`, true},
+		{"matching string id", `"7"`, false},
+		{"other id", `8`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			started := make(chan struct{})
+			err := s.RegisterTool(Tool{Name: "slow", InputSchema: InputSchema{Type: "object"}},
+				func(ctx context.Context, _ map[string]interface{}) (CallToolResult, error) {
+					close(started)
+					<-ctx.Done()
+					return CallToolResult{}, ctx.Err()
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, stop := context.WithCancel(initialized(t, s))
+			defer stop()
+
+			replies := make(chan JSONRPCResponse, 1)
+			go func() {
+				resp, _ := s.handleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"slow"}}`))
+				replies <- resp.(JSONRPCResponse)
+			}()
+			<-started
+			cancel := fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":%s}}`, tt.requestID)
+			if _, ok := s.handleMessage(ctx, []byte(cancel)); ok {
+				t.Fatal("notifications/cancelled got a reply")
+			}
+
+			select {
+			case resp := <-replies:
+				if !tt.cancelled {
+					t.Fatalf("call ended by a cancellation for another request: %+v", resp)
+				}
+				if resp.Error == nil || resp.Error.Code != -32800 {
+					t.Errorf("error = %+v, want -32800", resp.Error)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.cancelled {
+					t.Fatal("cancelled call didn't return promptly")
+				}
+				stop()
+				<-replies
+			}
+		})
+	}
+}
+
+func TestValidateOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		code int
+	}{
+		{"valid", map[string]interface{}{"n": 3}, 0},
+		{"missing required", map[string]interface{}{}, -32602},
+		{"wrong type", map[string]interface{}{"n": "three"}, -32602},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			var calls int
+			err := s.RegisterTool(Tool{
+				Name: "count",
+				InputSchema: InputSchema{
+					Type:       "object",
+					Properties: map[string]Property{"n": {Type: "integer"}},
+					Required:   []string{"n"},
+				},
+			}, func(context.Context, map[string]interface{}) (CallToolResult, error) {
+				calls++
+				return CallToolResult{}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := call(t, s, initialized(t, s), "tools/call", map[string]interface{}{
+				"name": "count", "arguments": tt.args, "validateOnly": true,
+			})
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.code {
+				t.Errorf("code = %d, want %d (%+v)", code, tt.code, resp.Error)
+			}
+			if code == 0 && toolResult(t, resp).IsError {
+				t.Error("valid arguments gave an error result")
+			}
+			if calls != 0 {
+				t.Errorf("handler ran %d times in validate mode", calls)
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	rd := &readiness{}
+	tests := []struct {
+		name   string
+		ready  bool
+		code   int
+		status string
+	}{
+		{"starting", false, http.StatusServiceUnavailable, "starting"},
+		{"ready", true, http.StatusOK, "ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.ready {
+				rd.markReady()
+			}
+			rec := httptest.NewRecorder()
+			rd.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.code {
+				t.Errorf("status = %d, want %d", rec.Code, tt.code)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["status"] != tt.status {
+				t.Errorf("body = %s, want status %q", rec.Body, tt.status)
+			}
+		})
+	}
+}
+
+func TestMethodNotFound(t *testing.T) {
+	tests := []struct {
+		method  string
+		present []string
+		absent  []string
+	}{
+		{"tools/lst", []string{"initialize", "ping", "tools/call", "tools/list"}, []string{"notifications/initialized", "admin/shutdown"}},
+		{"admin/restart", []string{"ping"}, []string{"admin/shutdown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			resp := call(t, NewMCPServer(), context.Background(), tt.method, nil)
+			if resp.Error == nil || resp.Error.Code != -32601 {
+				t.Fatalf("error = %+v, want -32601", resp.Error)
+			}
+			data, ok := resp.Error.Data.(MethodErrorData)
+			if !ok {
+				t.Fatalf("data is %T, want MethodErrorData", resp.Error.Data)
+			}
+			if data.Method != tt.method {
+				t.Errorf("method = %q, want %q", data.Method, tt.method)
+			}
+			for _, m := range tt.present {
+				if !slices.Contains(data.SupportedMethods, m) {
+					t.Errorf("supportedMethods lacks %s", m)
+				}
+			}
+			for _, m := range tt.absent {
+				if slices.Contains(data.SupportedMethods, m) {
+					t.Errorf("supportedMethods lists %s", m)
+				}
+			}
+			if !slices.IsSorted(data.SupportedMethods) {
+				t.Errorf("supportedMethods not sorted: %v", data.SupportedMethods)
+			}
+		})
+	}
+}
+
+// postMCP sends body to handleMCPRequest on the given session.
+func postMCP(s *MCPServer, sessionID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	rec := httptest.NewRecorder()
+	s.handleMCPRequest(rec, req)
+	return rec
+}
+
+func TestInitializedNotificationHasNoReply(t *testing.T) {
+	const (
+		initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+		notify     = `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	)
+	tests := []struct {
+		name      string
+		transport func(t *testing.T, s *MCPServer) []byte
+	}{
+		{"http", func(t *testing.T, s *MCPServer) []byte {
+			sessionID := postMCP(s, "", initialize).Header().Get("Mcp-Session-Id")
+			rec := postMCP(s, sessionID, notify)
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want 204", rec.Code)
+			}
+			return rec.Body.Bytes()
+		}},
+		{"stdio", func(t *testing.T, s *MCPServer) []byte {
+			var out bytes.Buffer
+			if err := s.serveStdio(strings.NewReader(notify+"\n"), &out, 0); err != nil {
+				t.Fatal(err)
+			}
+			return out.Bytes()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out := tt.transport(t, NewMCPServer()); len(out) != 0 {
+				t.Errorf("wrote %q, want nothing", out)
+			}
+		})
+	}
+}
+
+func TestNotificationsWriteNothing(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"cancelled", `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":9}}`},
+		{"unknown notification", `{"jsonrpc":"2.0","method":"notifications/whatever"}`},
+		{"request method without id", `{"jsonrpc":"2.0","method":"ping"}`},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			rec := postMCP(s, "", tt.body)
+			if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+				t.Errorf("http: status %d body %q, want 204 and nothing", rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "" {
+				t.Errorf("http: Content-Type = %q on an empty reply", ct)
+			}
+
+			var out bytes.Buffer
+			if err := s.serveStdio(strings.NewReader(tt.body+"\n"), &out, 0); err != nil {
+				t.Fatal(err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("stdio: wrote %q, want nothing", out.Bytes())
+			}
+		})
+	}
+}
+
+func TestAuthorizationServerMetadata(t *testing.T) {
+	endpoints, err := newCasdoorEndpoints("https://casdoor.example.com", []string{"openid", "profile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	oauthAuthorizationServerHandler(endpoints).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-authorization-server", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field string
+		want  []interface{}
+	}{
+		{"code_challenge_methods_supported", []interface{}{"S256"}},
+		{"response_modes_supported", []interface{}{"query"}},
+		{"response_types_supported", []interface{}{"code"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if got := metadata[tt.field]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataCaching(t *testing.T) {
+	endpoints, err := newCasdoorEndpoints("https://casdoor.example.com", []string{"openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := oauthAuthorizationServerHandler(endpoints)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/oauth-authorization-server", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	etag := get("").Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("ETag = %q, want a quoted tag", etag)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{"unconditional", "", http.StatusOK},
+		{"matching tag", etag, http.StatusNotModified},
+		{"weak match", "W/" + etag, http.StatusNotModified},
+		{"one of several", `"other", ` + etag, http.StatusNotModified},
+		{"wildcard", "*", http.StatusNotModified},
+		{"stale tag", `"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.ifNoneMatch)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if tt.wantCode == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 with body %q", rec.Body)
+			}
+			if tt.wantCode == http.StatusOK && !json.Valid(rec.Body.Bytes()) {
+				t.Errorf("body %q is not JSON", rec.Body)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesGet(t *testing.T) {
+	catalog := DefaultStoreCatalog()
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, s *MCPServer)
+	}{
+		{"tools and logging only", func(*testing.T, *MCPServer) {}},
+		{"with resources, prompts and completions", func(t *testing.T, s *MCPServer) {
+			s.RegisterResources(catalog)
+			s.RegisterCompletions(catalog)
+			if err := registerStorePrompts(s, catalog); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			tt.setup(t, s)
+			ctx := withSession(context.Background(), newSession())
+
+			if resp := call(t, s, ctx, "capabilities/get", nil); resp.Error == nil || resp.Error.Code != -32002 {
+				t.Fatalf("before initialize: error = %+v, want -32002", resp.Error)
+			}
+
+			resp := call(t, s, ctx, "initialize", map[string]interface{}{"protocolVersion": supportedVersions[0]})
+			init, ok := resp.Result.(InitializeResult)
+			if !ok {
+				t.Fatalf("initialize result = %+v", resp)
+			}
+			resp = call(t, s, ctx, "capabilities/get", nil)
+			got, ok := resp.Result.(CapabilitiesResult)
+			if !ok {
+				t.Fatalf("capabilities/get reply = %+v", resp)
+			}
+			if !reflect.DeepEqual(got.Capabilities, init.Capabilities) {
+				t.Errorf("capabilities/get = %+v, initialize advertised %+v", got.Capabilities, init.Capabilities)
+			}
+		})
+	}
+}
+
+func TestSlowToolWarning(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		sleep     time.Duration
+		wantWarn  bool
+	}{
+		{"slow tool", 10 * time.Millisecond, 30 * time.Millisecond, true},
+		{"fast tool", time.Second, 0, false},
+		{"disabled", 0, 30 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			prev := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+			t.Cleanup(func() { slog.SetDefault(prev) })
+
+			s := NewMCPServer()
+			s.SetSlowToolThreshold(tt.threshold)
+			err := s.RegisterTool(Tool{Name: "nap", InputSchema: InputSchema{Type: "object"}},
+				func(context.Context, map[string]interface{}) (CallToolResult, error) {
+					time.Sleep(tt.sleep)
+					return CallToolResult{Content: []Content{{Type: "text", Text: "ok"}}}, nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := initialized(t, s)
+			toolResult(t, call(t, s, ctx, "tools/call", map[string]interface{}{"name": "nap"}))
+
+			var warning map[string]interface{}
+			for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+				var rec map[string]interface{}
+				if json.Unmarshal(line, &rec) == nil && rec["msg"] == "Slow tool call" {
+					warning = rec
+				}
+			}
+			if (warning != nil) != tt.wantWarn {
+				t.Fatalf("slow-call warning logged = %v, want %v\n%s", warning != nil, tt.wantWarn, buf.String())
+			}
+			if warning == nil {
+				return
+			}
+			if warning["level"] != "WARN" || warning["tool"] != "nap" || warning["session"] != sessionFromContext(ctx).ID {
+				t.Errorf("warning = %v, want WARN for tool nap on session %s", warning, sessionFromContext(ctx).ID)
+			}
+			if d, _ := warning["duration"].(float64); time.Duration(d) < tt.sleep {
+				t.Errorf("duration = %v, want at least %v", warning["duration"], tt.sleep)
+			}
+		})
+	}
+}
+
+func TestFailingTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler ToolHandler
+		text    string
+	}{
+		{"returns an error", func(context.Context, map[string]interface{}) (CallToolResult, error) {
+			return CallToolResult{}, errors.New("upstream down")
+		}, "Tool execution failed: upstream down"},
+		{"marks its result", func(context.Context, map[string]interface{}) (CallToolResult, error) {
+			return CallToolResult{Content: []Content{{Type: "text", Text: "store not found: x"}}, IsError: true}, nil
+		}, "store not found: x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			if err := s.RegisterTool(Tool{Name: "fail", InputSchema: InputSchema{Type: "object"}}, tt.handler); err != nil {
+				t.Fatal(err)
+			}
+			// A tool failure is a result, not a protocol error
+			result := toolResult(t, call(t, s, initialized(t, s), "tools/call", map[string]interface{}{"name": "fail"}))
+			if !result.IsError {
+				t.Error("IsError not set")
+			}
+			if len(result.Content) != 1 || result.Content[0].Type != "text" || result.Content[0].Text != tt.text {
+				t.Errorf("content = %+v, want text %q", result.Content, tt.text)
+			}
+		})
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	s := NewMCPServer()
+	err := s.RegisterTool(Tool{Name: "boom", InputSchema: InputSchema{Type: "object"}},
+		func(context.Context, map[string]interface{}) (CallToolResult, error) {
+			panic("boom")
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := postMCP(s, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`).Header().Get("Mcp-Session-Id")
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		status  int
+	}{
+		{"tool panics", http.HandlerFunc(s.handleMCPRequest), http.StatusOK},
+		{"handler panics", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"boom"}}`))
+			req.Header.Set("Mcp-Session-Id", sessionID)
+			rec := httptest.NewRecorder()
+			logRequests(recoverPanics(tt.handler)).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var reply struct {
+				Error struct {
+					Code int `json:"code"`
+					Data struct {
+						RequestID string `json:"requestId"`
+					} `json:"data"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			if reply.Error.Code != -32603 {
+				t.Errorf("code = %d, want -32603", reply.Error.Code)
+			}
+			if want := rec.Header().Get("X-Request-Id"); reply.Error.Data.RequestID != want {
+				t.Errorf("requestId = %q, want %q", reply.Error.Data.RequestID, want)
+			}
+		})
+	}
+}
+
+func TestRequestIDRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string // raw JSON, "" for none
+		reply string // raw JSON id of the reply, "" for no reply
+	}{
+		{"integer", `7`, `7`},
+		{"zero", `0`, `0`},
+		{"negative", `-3`, `-3`},
+		{"beyond float64 precision", `12345678901234567890`, `12345678901234567890`},
+		{"fraction", `1.5`, `1.5`},
+		{"exponent", `1e3`, `1e3`},
+		{"string", `"abc"`, `"abc"`},
+		{"numeric string", `"7"`, `"7"`},
+		{"empty string", `""`, `""`},
+		{"missing", ``, ``},
+		{"null", `null`, ``},
+		{"object", `{"a":1}`, `null`},
+		{"boolean", `true`, `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := `{"jsonrpc":"2.0","method":"ping"}`
+			if tt.id != "" {
+				raw = `{"jsonrpc":"2.0","id":` + tt.id + `,"method":"ping"}`
+			}
+			resp, ok := NewMCPServer().handleMessage(context.Background(), []byte(raw))
+			if !ok {
+				if tt.reply != "" {
+					t.Fatal("no reply")
+				}
+				return
+			}
+			if tt.reply == "" {
+				t.Fatalf("replied %+v to a request without an id", resp)
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(fields["id"]); got != tt.reply {
+				t.Errorf("reply id = %s, want %s", got, tt.reply)
+			}
+		})
+	}
+}
+
+func TestCallToolRequiresName(t *testing.T) {
+	tests := []struct {
+		name   string
+		params interface{}
+	}{
+		{"empty params", map[string]interface{}{}},
+		{"empty name", map[string]interface{}{"name": ""}},
+		{"arguments only", map[string]interface{}{"arguments": map[string]interface{}{"category": "fashion"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			resp := call(t, s, ctx, "tools/call", tt.params)
+			if resp.Error == nil || resp.Error.Code != -32602 {
+				t.Fatalf("error = %+v, want -32602", resp.Error)
+			}
+			if !strings.Contains(resp.Error.Message, "name is required") {
+				t.Errorf("message = %q, want it to say name is required", resp.Error.Message)
+			}
+		})
+	}
+}
+
+// Params that aren't an object are refused by handleCallTool itself, not
+// only by the request validation in front of it.
+func TestCallToolMalformedParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		detail string
+	}{
+		{"absent", "", "params must be an object"},
+		{"null", "null", "params must be an object"},
+		{"array", "[]", "json: cannot unmarshal array into Go value of type main.CallToolParams"},
+		{"string", `"x"`, "json: cannot unmarshal string into Go value of type main.CallToolParams"},
+		{"number", "42", "json: cannot unmarshal number into Go value of type main.CallToolParams"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			resp := s.handleCallTool(ctx, 1, json.RawMessage(tt.params))
+			if resp.Error == nil || resp.Error.Code != -32602 {
+				t.Fatalf("error = %+v, want -32602", resp.Error)
+			}
+			if data, _ := resp.Error.Data.(DetailErrorData); data.Detail != tt.detail {
+				t.Errorf("data = %+v, want detail %q", resp.Error.Data, tt.detail)
+			}
+		})
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   int
+	}{
+		{"notification", `{"jsonrpc":"2.0","method":"notifications/foo"}`, http.StatusNoContent, 0},
+		{"notification with params", `{"jsonrpc":"2.0","method":"notifications/foo","params":{"x":1}}`, http.StatusNoContent, 0},
+		{"request", `{"jsonrpc":"2.0","id":1,"method":"notifications/foo"}`, http.StatusOK, -32601},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			rec := postMCP(NewMCPServer(), "", tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.code == 0 {
+				if rec.Body.Len() != 0 {
+					t.Errorf("body = %q, want none", rec.Body)
+				}
+				if !strings.Contains(logged.String(), "notifications/foo") {
+					t.Errorf("log = %q, want the ignored notification logged", logged.String())
+				}
+				return
+			}
+			var reply JSONRPCResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Error == nil || reply.Error.Code != tt.code {
+				t.Errorf("error = %+v, want %d", reply.Error, tt.code)
+			}
+		})
+	}
+}
+
+func TestMetaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		into interface{}
+		json string
+	}{
+		{
+			"params",
+			CallToolParams{Name: "x", Meta: map[string]interface{}{"progressToken": "p1", "vendor/trace": "abc"}},
+			&CallToolParams{},
+			`{"name":"x","_meta":{"progressToken":"p1","vendor/trace":"abc"}}`,
+		},
+		{
+			"result",
+			CallToolResult{Content: []Content{{Type: "text", Text: "ok"}}, Meta: map[string]interface{}{"cached": true}},
+			&CallToolResult{},
+			`{"content":[{"type":"text","text":"ok"}],"_meta":{"cached":true}}`,
+		},
+		{"result without meta", CallToolResult{Content: []Content{}}, &CallToolResult{}, `{"content":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Errorf("marshal = %s, want %s", data, tt.json)
+			}
+			if err := json.Unmarshal(data, tt.into); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(tt.into).Elem().Interface(); !reflect.DeepEqual(got, tt.v) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.v)
+			}
+		})
+	}
+}
+
+// A tool's result _meta reaches the client.
+func TestResultMetaReturned(t *testing.T) {
+	s := NewMCPServer()
+	err := s.RegisterTool(Tool{Name: "meta", InputSchema: InputSchema{Type: "object"}},
+		func(context.Context, map[string]interface{}) (CallToolResult, error) {
+			return CallToolResult{Content: []Content{}, Meta: map[string]interface{}{"source": "catalog"}}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := toolResult(t, call(t, s, initialized(t, s), "tools/call", map[string]interface{}{"name": "meta", "_meta": map[string]interface{}{"progressToken": 1}}))
+	if result.Meta["source"] != "catalog" {
+		t.Errorf("_meta = %v, want the tool's", result.Meta)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//
+// --------------------
+// Metrics
+// --------------------
+//
+
+// Metrics holds the Prometheus collectors for the MCP server. A nil *Metrics
+// is valid and records nothing, so instrumentation points need no checks.
+type Metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	toolCalls *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_rpc_requests_total",
+			Help: "JSON-RPC requests handled, by method.",
+		}, []string{"method"}),
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_tool_calls_total",
+			Help: "Tool calls, by tool name and outcome (success or error).",
+		}, []string{"tool", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mcp_rpc_duration_seconds",
+			Help:    "Time spent handling JSON-RPC requests, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.toolCalls,
+		m.latency,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) observeRequest(method string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(method).Inc()
+	m.latency.WithLabelValues(method).Observe(elapsed.Seconds())
+}
+
+func (m *Metrics) observeToolCall(tool string, failed bool) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	if failed {
+		outcome = "error"
+	}
+	m.toolCalls.WithLabelValues(tool, outcome).Inc()
+}
+
+// SetMetrics enables instrumentation of requests and tool calls. It must be
+// called before the server starts handling requests.
+func (s *MCPServer) SetMetrics(m *Metrics) {
+	s.metrics = m
+}
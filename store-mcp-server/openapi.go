@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+//
+// --------------------
+// OpenAPI description
+// --------------------
+//
+
+// OpenAPI is the subset of an OpenAPI 3.1 document /openapi.json uses.
+type OpenAPI struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       OpenAPIInfo         `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components OpenAPIComponents   `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      interface{} `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema interface{} `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]interface{} `json:"schemas"`
+}
+
+func ref(name string) map[string]string {
+	return map[string]string{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schema interface{}) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// openAPIDocument describes every HTTP endpoint main serves, including the
+// ones only mounted with -debug or -metrics. JSON-RPC bodies are modelled
+// only as far as the envelope; the methods themselves are discoverable
+// through MCP.
+func openAPIDocument(title string) OpenAPI {
+	sessionHeader := Parameter{
+		Name:        "Mcp-Session-Id",
+		In:          "header",
+		Description: "Session returned by initialize",
+		Schema:      map[string]string{"type": "string"},
+	}
+	unauthorized := Response{Description: "Missing or invalid bearer token; see WWW-Authenticate"}
+
+	return OpenAPI{
+		OpenAPI: "3.1.0",
+		Info:    OpenAPIInfo{Title: title, Version: Version},
+		Paths: map[string]PathItem{
+			"/mcp": {
+				Post: &Operation{
+					Summary:    "Send a JSON-RPC request, notification or batch",
+					Parameters: []Parameter{sessionHeader},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content: jsonContent(map[string]interface{}{
+							"oneOf": []interface{}{
+								ref("JSONRPCRequest"),
+								map[string]interface{}{"type": "array", "items": ref("JSONRPCRequest")},
+							},
+						}),
+					},
+					Responses: map[string]Response{
+						"200": {Description: "JSON-RPC response or batch of responses", Content: jsonContent(map[string]interface{}{
+							"oneOf": []interface{}{
+								ref("JSONRPCResponse"),
+								map[string]interface{}{"type": "array", "items": ref("JSONRPCResponse")},
+							},
+						})},
+						"204": {Description: "The message held only notifications"},
+						"401": unauthorized,
+						"503": {Description: "Too many requests in flight (-max-concurrency); retry after Retry-After seconds", Content: jsonContent(ref("JSONRPCResponse"))},
+					},
+				},
+				Delete: &Operation{
+					Summary:    "End a session",
+					Parameters: []Parameter{sessionHeader},
+					Responses: map[string]Response{
+						"204": {Description: "Session ended"},
+						"404": {Description: "Unknown session"},
+					},
+				},
+			},
+			"/mcp/sse": {
+				Get: &Operation{
+					Summary: "Open a server-sent event stream; the first \"endpoint\" event gives the URL to POST messages to",
+					Parameters: []Parameter{{
+						Name:        "chunked",
+						In:          "query",
+						Description: "With 1, large tool results arrive as \"chunk\" events before the final \"message\" event",
+						Schema:      map[string]string{"type": "string"},
+					}},
+					Responses: map[string]Response{
+						"200": {Description: "Event stream of JSON-RPC responses and notifications", Content: map[string]MediaType{
+							"text/event-stream": {Schema: map[string]string{"type": "string"}},
+						}},
+						"401": unauthorized,
+					},
+				},
+			},
+			"/mcp/sse/message": {
+				Post: &Operation{
+					Summary: "Send a JSON-RPC message for an open event stream; the reply arrives on the stream",
+					Parameters: []Parameter{{
+						Name:        "sessionId",
+						In:          "query",
+						Description: "Session from the stream's endpoint event",
+						Required:    true,
+						Schema:      map[string]string{"type": "string"},
+					}},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content: jsonContent(map[string]interface{}{
+							"oneOf": []interface{}{
+								ref("JSONRPCRequest"),
+								map[string]interface{}{"type": "array", "items": ref("JSONRPCRequest")},
+							},
+						}),
+					},
+					Responses: map[string]Response{
+						"202": {Description: "Accepted; any reply is sent as a \"message\" event"},
+						"400": {Description: "The body couldn't be read"},
+						"401": unauthorized,
+						"404": {Description: "Unknown session"},
+					},
+				},
+			},
+			"/mcp/ws": {
+				Get: &Operation{
+					Summary: "Upgrade to a WebSocket carrying one JSON-RPC message per text frame",
+					Responses: map[string]Response{
+						"101": {Description: "Switching protocols"},
+						"401": unauthorized,
+						"403": {Description: "Origin not allowed (-allowed-origins, -cors-origins)"},
+					},
+				},
+			},
+			"/tools": {
+				Get: &Operation{
+					Summary: "The tools tools/list returns, as a plain array; only with -debug",
+					Responses: map[string]Response{
+						"200": {Description: "Registered tools", Content: jsonContent(map[string]interface{}{"type": "array", "items": map[string]string{"type": "object"}})},
+						"401": unauthorized,
+					},
+				},
+			},
+			"/debug/audit": {
+				Get: &Operation{
+					Summary: "Recent tool calls, oldest first; only with -debug",
+					Responses: map[string]Response{
+						"200": {Description: "Audit log entries", Content: jsonContent(ref("AuditLog"))},
+						"401": unauthorized,
+					},
+				},
+			},
+			"/openapi.json": {
+				Get: &Operation{
+					Summary:   "This document",
+					Responses: map[string]Response{"200": {Description: "OpenAPI 3.1 document", Content: jsonContent(map[string]string{"type": "object"})}},
+				},
+			},
+			"/health": {
+				Get: &Operation{
+					Summary: "Liveness probe",
+					Parameters: []Parameter{{
+						Name:        "deep",
+						In:          "query",
+						Description: "Also check that Casdoor's JWKS endpoint answers",
+						Schema:      map[string]string{"type": "boolean"},
+					}},
+					Responses: map[string]Response{
+						"200": {Description: "The process is serving", Content: jsonContent(ref("Status"))},
+						"503": {Description: "Deep check only: Casdoor is unreachable", Content: jsonContent(ref("Status"))},
+					},
+				},
+			},
+			"/readyz": {
+				Get: &Operation{
+					Summary: "Readiness probe",
+					Responses: map[string]Response{
+						"200": {Description: "Startup work has finished", Content: jsonContent(ref("Status"))},
+						"503": {Description: "Still starting", Content: jsonContent(ref("Status"))},
+					},
+				},
+			},
+			"/version": {
+				Get: &Operation{
+					Summary:   "Build metadata",
+					Responses: map[string]Response{"200": {Description: "The running build", Content: jsonContent(ref("BuildInfo"))}},
+				},
+			},
+			"/stats": {
+				Get: &Operation{
+					Summary:   "Uptime, request counts by method and open sessions",
+					Responses: map[string]Response{"200": {Description: "Counters since the process started", Content: jsonContent(ref("Stats"))}},
+				},
+			},
+			"/metrics": {
+				Get: &Operation{
+					Summary: "Prometheus metrics, when enabled with -metrics",
+					Responses: map[string]Response{"200": {Description: "Prometheus text exposition format", Content: map[string]MediaType{
+						"text/plain": {Schema: map[string]string{"type": "string"}},
+					}}},
+				},
+			},
+			"/.well-known/oauth-authorization-server": {
+				Get: &Operation{
+					Summary:   "OAuth 2.0 authorization server metadata (RFC 8414) pointing at Casdoor",
+					Responses: map[string]Response{"200": {Description: "Metadata", Content: jsonContent(map[string]string{"type": "object"})}},
+				},
+			},
+			"/.well-known/oauth-protected-resource": {
+				Get: &Operation{
+					Summary:   "OAuth 2.0 protected resource metadata (RFC 9728) for /mcp",
+					Responses: map[string]Response{"200": {Description: "Metadata", Content: jsonContent(map[string]string{"type": "object"})}},
+				},
+			},
+		},
+		Components: OpenAPIComponents{
+			Schemas: map[string]interface{}{
+				"JSONRPCRequest": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"jsonrpc", "method"},
+					"properties": map[string]interface{}{
+						"jsonrpc": map[string]interface{}{"const": "2.0"},
+						"id":      map[string]interface{}{"type": []string{"string", "number"}, "description": "Omitted for notifications"},
+						"method":  map[string]string{"type": "string"},
+						"params":  map[string]string{"type": "object"},
+					},
+				},
+				"JSONRPCResponse": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"jsonrpc", "id"},
+					"properties": map[string]interface{}{
+						"jsonrpc": map[string]interface{}{"const": "2.0"},
+						"id":      map[string]interface{}{"type": []string{"string", "number", "null"}},
+						"result":  map[string]interface{}{},
+						"error":   ref("RPCError"),
+					},
+				},
+				"RPCError": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": map[string]interface{}{
+						"code":    map[string]string{"type": "integer"},
+						"message": map[string]string{"type": "string"},
+						"data":    map[string]interface{}{},
+					},
+				},
+				"Status": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"status":  map[string]string{"type": "string"},
+						"casdoor": map[string]string{"type": "string"},
+					},
+				},
+				"Stats": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"startedAt":      map[string]string{"type": "string", "format": "date-time"},
+						"uptimeSeconds":  map[string]string{"type": "number"},
+						"requests":       map[string]string{"type": "integer"},
+						"methods":        map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"type": "integer"}},
+						"activeSessions": map[string]string{"type": "integer"},
+					},
+				},
+				"AuditLog": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"entries": map[string]interface{}{
+							"type": "array",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"time":       map[string]string{"type": "string", "format": "date-time"},
+									"tool":       map[string]string{"type": "string"},
+									"arguments":  map[string]string{"type": "string"},
+									"outcome":    map[string]interface{}{"enum": []string{"success", "error", "timeout", "cancelled"}},
+									"error":      map[string]string{"type": "string"},
+									"durationMs": map[string]string{"type": "number"},
+								},
+							},
+						},
+					},
+				},
+				"BuildInfo": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name":      map[string]string{"type": "string"},
+						"version":   map[string]string{"type": "string"},
+						"commit":    map[string]string{"type": "string"},
+						"buildDate": map[string]string{"type": "string"},
+						"goVersion": map[string]string{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openAPIHandler serves the document for the server called title.
+func openAPIHandler(title string) http.HandlerFunc {
+	doc, err := json.Marshal(openAPIDocument(title))
+	if err != nil {
+		panic(err)
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	rec := httptest.NewRecorder()
+	openAPIHandler("test-server")(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var doc struct {
+		OpenAPI    string                     `json:"openapi"`
+		Info       OpenAPIInfo                `json:"info"`
+		Paths      map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "test-server" {
+		t.Errorf("openapi %q, title %q", doc.OpenAPI, doc.Info.Title)
+	}
+
+	paths := []string{
+		"/mcp", "/mcp/sse", "/mcp/sse/message", "/mcp/ws",
+		"/health", "/readyz", "/version", "/stats", "/metrics", "/openapi.json",
+		"/tools", "/debug/audit",
+		"/.well-known/oauth-authorization-server", "/.well-known/oauth-protected-resource",
+	}
+	for _, p := range paths {
+		if _, ok := doc.Paths[p]; !ok {
+			t.Errorf("path %s is not documented", p)
+		}
+	}
+
+	// Every $ref must resolve to a component schema
+	for _, ref := range strings.Split(rec.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.IndexByte(ref, '"')]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("$ref to undefined schema %s", name)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//
+// --------------------
+// Origin validation
+// --------------------
+//
+
+// OriginGuard rejects browser requests from origins outside an allowlist.
+// A server on localhost is otherwise reachable from any web page through DNS
+// rebinding. Requests without an Origin header don't come from a browser
+// page and are let through.
+type OriginGuard struct {
+	allowed []*url.URL
+}
+
+// NewOriginGuard parses a comma-separated list of origins. An entry without
+// a port, like http://localhost, matches that host on any port.
+func NewOriginGuard(origins string) *OriginGuard {
+	g := &OriginGuard{}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSpace(o); o == "" {
+			continue
+		}
+		if u, err := url.Parse(o); err == nil && u.Host != "" {
+			g.allowed = append(g.allowed, u)
+		}
+	}
+	return g
+}
+
+func (g *OriginGuard) allows(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, a := range g.allowed {
+		if !strings.EqualFold(a.Scheme, u.Scheme) || !strings.EqualFold(a.Hostname(), u.Hostname()) {
+			continue
+		}
+		if a.Port() == "" || a.Port() == u.Port() {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *OriginGuard) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !g.allows(origin) {
+			http.Error(w, "Forbidden origin", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local
+// connections. An empty host listens on every interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+//
+// --------------------
+// List pagination
+// --------------------
+//
+
+// defaultPageSize is how many items a list method returns per page.
+const defaultPageSize = 50
+
+// PaginatedParams is the optional params object of the */list methods.
+type PaginatedParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// parseCursor decodes the params of a list request into a start offset.
+// Missing params or an empty cursor start from the beginning.
+func parseCursor(params json.RawMessage) (int, error) {
+	if len(params) == 0 || string(params) == "null" {
+		return 0, nil
+	}
+	var p PaginatedParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return 0, err
+	}
+	if p.Cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// page returns the bounds of the page starting at offset in a list of n
+// items, and the cursor for the following page ("" on the last one). An
+// offset past the end means the cursor is stale.
+func page(offset, n, size int) (start, end int, next string, err error) {
+	if offset > n || (offset == n && n > 0) {
+		return 0, 0, "", errInvalidCursor
+	}
+	end = offset + size
+	if end >= n {
+		return offset, n, "", nil
+	}
+	return offset, end, encodeCursor(end), nil
+}
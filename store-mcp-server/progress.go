@@ -0,0 +1,57 @@
+package main
+
+import "context"
+
+//
+// --------------------
+// Progress notifications
+// --------------------
+//
+
+type JSONRPCNotification struct {
+	JsonRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// notifier delivers a server-initiated notification to the client on the
+// connection a request came in on.
+type notifier func(JSONRPCNotification)
+
+// withNotifier lets handlers below ctx push notifications. Transports that
+// can't deliver them mid-request (plain HTTP POST) don't set one.
+func withNotifier(ctx context.Context, notify notifier) context.Context {
+	return context.WithValue(ctx, notifierContextKey, notify)
+}
+
+func withProgressToken(ctx context.Context, token interface{}) context.Context {
+	return context.WithValue(ctx, progressContextKey, token)
+}
+
+// reportProgress tells the client how far a tool call has got. It does
+// nothing unless the client asked for progress and the transport can send
+// it, so handlers may call it unconditionally.
+func reportProgress(ctx context.Context, progress, total float64, message string) {
+	token := ctx.Value(progressContextKey)
+	notify, ok := ctx.Value(notifierContextKey).(notifier)
+	if token == nil || !ok {
+		return
+	}
+	notify(JSONRPCNotification{
+		JsonRPC: "2.0",
+		Method:  "notifications/progress",
+		Params: ProgressParams{
+			ProgressToken: token,
+			Progress:      progress,
+			Total:         total,
+			Message:       message,
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestExportReportsProgress(t *testing.T) {
+	stores := DefaultStoreCatalog().Len()
+	tests := []struct {
+		name   string
+		meta   map[string]interface{}
+		notify bool
+		want   int
+	}{
+		{"token and notifier", map[string]interface{}{"progressToken": "p1"}, true, stores},
+		{"no token", nil, true, 0},
+		{"no notifier", map[string]interface{}{"progressToken": "p1"}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			var got []ProgressParams
+			if tt.notify {
+				ctx = withNotifier(ctx, func(n JSONRPCNotification) {
+					if n.Method == "notifications/progress" {
+						got = append(got, n.Params.(ProgressParams))
+					}
+				})
+			}
+			params := map[string]interface{}{"name": "export_catalog"}
+			if tt.meta != nil {
+				params["_meta"] = tt.meta
+			}
+			toolResult(t, call(t, s, ctx, "tools/call", params))
+
+			if len(got) != tt.want {
+				t.Fatalf("got %d progress notifications, want %d", len(got), tt.want)
+			}
+			for i, p := range got {
+				if p.ProgressToken != "p1" || p.Progress != float64(i+1) || p.Total != float64(stores) {
+					t.Errorf("notification %d = %+v", i, p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//
+// --------------------
+// Prompts
+// --------------------
+//
+
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptHandler renders a prompt with the client-supplied arguments.
+type PromptHandler func(args map[string]string) (GetPromptResult, error)
+
+type registeredPrompt struct {
+	prompt  Prompt
+	handler PromptHandler
+}
+
+// RegisterPrompt makes a prompt available to prompts/list and prompts/get.
+// Registering a name twice is an error.
+func (s *MCPServer) RegisterPrompt(p Prompt, handler PromptHandler) error {
+	if p.Name == "" {
+		return fmt.Errorf("prompt name is required")
+	}
+	if handler == nil {
+		return fmt.Errorf("prompt %q: handler is required", p.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.prompts[p.Name]; exists {
+		return fmt.Errorf("prompt %q already registered", p.Name)
+	}
+	s.prompts[p.Name] = &registeredPrompt{prompt: p, handler: handler}
+	s.promptOrder = append(s.promptOrder, p.Name)
+	return nil
+}
+
+func (s *MCPServer) hasPrompts() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.prompts) > 0
+}
+
+func (s *MCPServer) handlePromptsList(id interface{}) JSONRPCResponse {
+	s.mu.RLock()
+	prompts := make([]Prompt, 0, len(s.promptOrder))
+	for _, name := range s.promptOrder {
+		prompts = append(prompts, s.prompts[name].prompt)
+	}
+	s.mu.RUnlock()
+
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  PromptsListResult{Prompts: prompts},
+	}
+}
+
+func (s *MCPServer) handlePromptsGet(id interface{}, params json.RawMessage) JSONRPCResponse {
+	var getParams GetPromptParams
+	if err := json.Unmarshal(params, &getParams); err != nil {
+		return newInvalidParams(id, err)
+	}
+
+	s.mu.RLock()
+	rp, ok := s.prompts[getParams.Name]
+	s.mu.RUnlock()
+	if !ok {
+		return s.sendError(id, -32602, "Unknown prompt: "+getParams.Name, NotFoundErrorData{Name: getParams.Name})
+	}
+
+	var missing []string
+	var fields []FieldError
+	for _, arg := range rp.prompt.Arguments {
+		if arg.Required && strings.TrimSpace(getParams.Arguments[arg.Name]) == "" {
+			missing = append(missing, arg.Name)
+			fields = append(fields, FieldError{Property: arg.Name, Reason: reasonMissing})
+		}
+	}
+	if len(missing) > 0 {
+		return newValidationError(id, "Missing required arguments for prompt "+getParams.Name+": "+strings.Join(missing, ", "), fields)
+	}
+
+	result, err := rp.handler(getParams.Arguments)
+	if err != nil {
+		return s.sendError(id, -32603, "Prompt rendering failed", DetailErrorData{Detail: err.Error()})
+	}
+
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  result,
+	}
+}
+
+//
+// --------------------
+// Store prompts
+// --------------------
+//
+
+// registerStorePrompts adds the built-in store prompts, backed by catalog, to s.
+func registerStorePrompts(s *MCPServer, catalog *StoreCatalog) error {
+	return s.RegisterPrompt(Prompt{
+		Name:        "recommend_store",
+		Description: "Ask the model to recommend an Indian online store for a product category",
+		Arguments: []PromptArgument{
+			{Name: "product_category", Description: "What the user wants to buy, e.g. smartphones or ethnic wear", Required: true},
+		},
+	}, catalog.recommendStorePrompt)
+}
+
+func (c *StoreCatalog) recommendStorePrompt(args map[string]string) (GetPromptResult, error) {
+	category := args["product_category"]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "I want to buy %s online in India. ", category)
+	b.WriteString("Recommend the best store from the list below and briefly explain why. ")
+	b.WriteString("Only recommend stores from this list.\n\n")
+	for _, store := range c.stores {
+		fmt.Fprintf(&b, "- %s (%s, %s)", store.Name, store.Category, store.URL)
+		if store.Description != "" {
+			fmt.Fprintf(&b, ": %s", store.Description)
+		}
+		b.WriteString("\n")
+	}
+
+	return GetPromptResult{
+		Description: "Store recommendation for " + category,
+		Messages: []PromptMessage{
+			{Role: "user", Content: Content{Type: "text", Text: b.String()}},
+		},
+	}, nil
+}
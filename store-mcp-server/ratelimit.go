@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//
+// --------------------
+// Rate limiting
+// --------------------
+//
+
+// RateLimiter is a set of token buckets, one per key, each refilling at rate
+// tokens per second up to burst.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it returns
+// false and how long until a token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// prune forgets buckets that have been full for a while, so one-off clients
+// don't accumulate.
+func (l *RateLimiter) prune(idle time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// SetRateLimiter throttles tools/call per client. It must be called before
+// the server starts handling requests.
+func (s *MCPServer) SetRateLimiter(l *RateLimiter) {
+	s.limiter = l
+}
+
+// rateLimitKey identifies the client a call is charged to: its session when
+// authenticated, otherwise its address, since sessions are free to create.
+func rateLimitKey(ctx context.Context) string {
+	if _, ok := claimsFromContext(ctx); !ok {
+		if addr, ok := ctx.Value(clientAddrContextKey).(string); ok {
+			return "addr:" + addr
+		}
+	}
+	if sess := sessionFromContext(ctx); sess != nil {
+		return "session:" + sess.ID
+	}
+	return ""
+}
+
+// withClientAddr records the remote host of r for rate limiting.
+func withClientAddr(ctx context.Context, r *http.Request) context.Context {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return context.WithValue(ctx, clientAddrContextKey, host)
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds, as Retry-After wants.
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// setRetryAfter adds a Retry-After header when a single reply was rate
+// limited.
+func setRetryAfter(w http.ResponseWriter, resp interface{}) {
+	single, ok := resp.(JSONRPCResponse)
+	if !ok || single.Error == nil || single.Error.Code != -32099 {
+		return
+	}
+	if data, ok := single.Error.Data.(RateLimitErrorData); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(data.RetryAfterSeconds))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type step struct {
+		key     string
+		advance time.Duration
+		allowed bool
+		wait    time.Duration
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{"burst then refuse", 1, 2, []step{
+			{"a", 0, true, 0},
+			{"a", 0, true, 0},
+			{"a", 0, false, time.Second},
+		}},
+		{"refills over time", 2, 1, []step{
+			{"a", 0, true, 0},
+			{"a", 0, false, 500 * time.Millisecond},
+			{"a", 250 * time.Millisecond, false, 250 * time.Millisecond},
+			{"a", 250 * time.Millisecond, true, 0},
+		}},
+		{"keys are independent", 1, 1, []step{
+			{"a", 0, true, 0},
+			{"b", 0, true, 0},
+			{"a", 0, false, time.Second},
+		}},
+		{"burst below one acts as one", 1, 0, []step{
+			{"a", 0, true, 0},
+			{"a", 0, false, time.Second},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			l := NewRateLimiter(tt.rate, tt.burst)
+			l.now = func() time.Time { return now }
+			for i, st := range tt.steps {
+				now = now.Add(st.advance)
+				allowed, wait := l.Allow(st.key)
+				if allowed != st.allowed || wait != st.wait {
+					t.Errorf("step %d: Allow(%q) = %v, %s; want %v, %s", i, st.key, allowed, wait, st.allowed, st.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitedToolCall(t *testing.T) {
+	s := NewMCPServer()
+	s.SetRateLimiter(NewRateLimiter(0.5, 1))
+	ctx := initialized(t, s)
+
+	call(t, s, ctx, "tools/call", map[string]interface{}{"name": "missing"})
+	resp := call(t, s, ctx, "tools/call", map[string]interface{}{"name": "missing"})
+	if resp.Error == nil || resp.Error.Code != -32099 {
+		t.Fatalf("error = %+v, want -32099", resp.Error)
+	}
+	if data, ok := resp.Error.Data.(RateLimitErrorData); !ok || data.RetryAfterSeconds != 2 {
+		t.Errorf("data = %+v, want a 2s retry", resp.Error.Data)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+//
+// --------------------
+// Resources
+// --------------------
+//
+
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // base64, for binary contents
+}
+
+// ResourceTemplate describes a family of resources by an RFC 6570 URI
+// template, so clients can build URIs and complete their variables.
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// ResourceProvider exposes a set of readable resources to MCP clients.
+type ResourceProvider interface {
+	ListResources() []Resource
+	ListResourceTemplates() []ResourceTemplate
+	ReadResource(uri string) (ResourceContents, bool)
+}
+
+// RegisterResources sets the provider backing resources/list and
+// resources/read. The resources capability is only advertised once a
+// provider is registered.
+func (s *MCPServer) RegisterResources(p ResourceProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources = p
+}
+
+func (s *MCPServer) resourceProvider() ResourceProvider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resources
+}
+
+func (s *MCPServer) handleResourcesList(id interface{}) JSONRPCResponse {
+	resources := []Resource{}
+	if p := s.resourceProvider(); p != nil {
+		resources = p.ListResources()
+	}
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  ResourcesListResult{Resources: resources},
+	}
+}
+
+func (s *MCPServer) handleResourceTemplatesList(id interface{}) JSONRPCResponse {
+	templates := []ResourceTemplate{}
+	if p := s.resourceProvider(); p != nil {
+		templates = p.ListResourceTemplates()
+	}
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  ResourceTemplatesListResult{ResourceTemplates: templates},
+	}
+}
+
+func (s *MCPServer) handleResourcesRead(id interface{}, params json.RawMessage) JSONRPCResponse {
+	var readParams ReadResourceParams
+	if err := json.Unmarshal(params, &readParams); err != nil {
+		return newInvalidParams(id, err)
+	}
+
+	p := s.resourceProvider()
+	if p == nil {
+		return s.sendError(id, -32002, "Resource not found", NotFoundErrorData{Name: readParams.URI})
+	}
+	contents, ok := p.ReadResource(readParams.URI)
+	if !ok {
+		return s.sendError(id, -32002, "Resource not found", NotFoundErrorData{Name: readParams.URI})
+	}
+
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Result:  ReadResourceResult{Contents: []ResourceContents{contents}},
+	}
+}
+
+//
+// --------------------
+// Store resources
+// --------------------
+//
+
+const storeURIScheme = "store://"
+
+// storeURITemplate covers every store resource; completion/complete
+// suggests values for its name.
+const storeURITemplate = storeURIScheme + "{name}"
+
+func storeURI(name string) string {
+	return storeURIScheme + url.PathEscape(name)
+}
+
+// ListResources exposes each store as a store://<name> resource.
+func (c *StoreCatalog) ListResources() []Resource {
+	resources := make([]Resource, 0, len(c.stores))
+	for _, store := range c.stores {
+		resources = append(resources, Resource{
+			URI:         storeURI(store.Name),
+			Name:        store.Name,
+			Description: store.Description,
+			MimeType:    "application/json",
+		})
+	}
+	return resources
+}
+
+// ListResourceTemplates advertises store://{name}.
+func (c *StoreCatalog) ListResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{{
+		URITemplate: storeURITemplate,
+		Name:        "Store details",
+		Description: "Details of one store in the catalog, by store name",
+		MimeType:    "application/json",
+	}}
+}
+
+// ReadResource returns the store details for a store://<name> URI.
+func (c *StoreCatalog) ReadResource(uri string) (ResourceContents, bool) {
+	if !strings.HasPrefix(uri, storeURIScheme) {
+		return ResourceContents{}, false
+	}
+	name, err := url.PathUnescape(strings.TrimPrefix(uri, storeURIScheme))
+	if err != nil {
+		return ResourceContents{}, false
+	}
+
+	store, ok := c.Find(name)
+	if !ok {
+		return ResourceContents{}, false
+	}
+	data, err := json.Marshal(store)
+	if err != nil {
+		return ResourceContents{}, false
+	}
+	return ResourceContents{URI: uri, MimeType: "application/json", Text: string(data)}, true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+//
+// --------------------
+// Tool result cache
+// --------------------
+//
+
+// maxResultCacheEntries bounds the cache; once full, new results aren't
+// cached until old ones expire.
+const maxResultCacheEntries = 1000
+
+type cachedResult struct {
+	result  CallToolResult
+	expires time.Time
+}
+
+// ResultCache memoizes successful results of tools marked Cacheable, keyed
+// by tool name and arguments.
+type ResultCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedResult
+}
+
+func NewResultCache(ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cachedResult),
+	}
+}
+
+// SetResultCache enables caching of Cacheable tools' results.
+func (s *MCPServer) SetResultCache(c *ResultCache) {
+	s.cache = c
+}
+
+// resultCacheKey identifies a call. encoding/json sorts map keys, so equal
+// arguments always produce the same key.
+func resultCacheKey(tool string, args map[string]interface{}) (string, bool) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return tool + "\x00" + string(data), true
+}
+
+// Get returns an unexpired result for key. It always misses on a nil cache.
+func (c *ResultCache) Get(key string) (CallToolResult, bool) {
+	if c == nil {
+		return CallToolResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return CallToolResult{}, false
+	}
+	if c.now().After(entry.expires) {
+		delete(c.entries, key)
+		return CallToolResult{}, false
+	}
+	return entry.result, true
+}
+
+// Put stores result under key until the TTL passes.
+func (c *ResultCache) Put(key string, result CallToolResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= maxResultCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxResultCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cachedResult{result: result, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	const ttl = time.Minute
+	tests := []struct {
+		name      string
+		cacheable bool
+		isError   bool
+		second    map[string]interface{}
+		advance   time.Duration
+		wantCalls int
+	}{
+		{"identical call hits", true, false, map[string]interface{}{"q": "a"}, 0, 1},
+		{"other arguments miss", true, false, map[string]interface{}{"q": "b"}, 0, 2},
+		{"expired entry misses", true, false, map[string]interface{}{"q": "a"}, ttl + time.Second, 2},
+		{"not cacheable", false, false, map[string]interface{}{"q": "a"}, 0, 2},
+		{"error results aren't cached", true, true, map[string]interface{}{"q": "a"}, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			cache := NewResultCache(ttl)
+			cache.now = func() time.Time { return now }
+			s := NewMCPServer()
+			s.SetResultCache(cache)
+
+			var calls int
+			err := s.RegisterTool(Tool{
+				Name:        "lookup",
+				InputSchema: InputSchema{Type: "object", Properties: map[string]Property{"q": {Type: "string"}}},
+				Cacheable:   tt.cacheable,
+			}, func(context.Context, map[string]interface{}) (CallToolResult, error) {
+				calls++
+				return CallToolResult{Content: []Content{{Type: "text", Text: "result"}}, IsError: tt.isError}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := initialized(t, s)
+
+			first := toolResult(t, callTool(t, s, ctx, "lookup", map[string]interface{}{"q": "a"}))
+			now = now.Add(tt.advance)
+			second := toolResult(t, callTool(t, s, ctx, "lookup", tt.second))
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if second.Content[0].Text != first.Content[0].Text {
+				t.Errorf("second result %+v differs from first %+v", second, first)
+			}
+		})
+	}
+}
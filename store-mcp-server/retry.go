@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+//
+// --------------------
+// Outbound retries
+// --------------------
+//
+
+// RetryPolicy controls how calls to Casdoor are retried. Transport errors,
+// 5xx and 429 responses are retried; any other 4xx is final.
+type RetryPolicy struct {
+	MaxAttempts    int
+	BaseDelay      time.Duration // doubled after each failed attempt
+	MaxDelay       time.Duration
+	AttemptTimeout time.Duration // 0 leaves each attempt unbounded
+}
+
+// backoff returns how long to wait after the given failed attempt (1-based):
+// exponential growth capped at MaxDelay, with jitter so that replicas
+// retrying together don't stay in lockstep.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Between half and all of d
+	return d/2 + rand.N(d/2+1)
+}
+
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// retryTransport applies a RetryPolicy around another RoundTripper.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := t.policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	// A body we can't rewind can only be sent once
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.try(req, attempt)
+		if attempt == attempts || (err == nil && !retryableStatus(resp.StatusCode)) {
+			return resp, err
+		}
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.policy.backoff(attempt)):
+		}
+	}
+}
+
+func (t *retryTransport) try(req *http.Request, attempt int) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	if t.policy.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), t.policy.AttemptTimeout)
+	}
+
+	r := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The attempt's deadline must keep covering the body until it's read
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		failures   int
+		failStatus int
+		want       int
+		attempts   int32
+	}{
+		{"succeeds after two 503s", http.MethodGet, 2, http.StatusServiceUnavailable, http.StatusOK, 3},
+		{"429 is retried", http.MethodGet, 1, http.StatusTooManyRequests, http.StatusOK, 2},
+		{"gives up after max attempts", http.MethodGet, 5, http.StatusBadGateway, http.StatusBadGateway, 3},
+		{"4xx is final", http.MethodPost, 2, http.StatusUnauthorized, http.StatusUnauthorized, 1},
+		{"POST with rewindable body retried", http.MethodPost, 1, http.StatusServiceUnavailable, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if n := attempts.Add(1); int(n) <= tt.failures {
+					w.WriteHeader(tt.failStatus)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: &retryTransport{
+				base:   http.DefaultTransport,
+				policy: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+			}}
+			req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader("token=x"))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("server saw %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want within [%s, %s]", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
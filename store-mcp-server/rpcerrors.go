@@ -0,0 +1,107 @@
+package main
+
+//
+// --------------------
+// Error data
+// --------------------
+//
+
+// RPCError.Data always holds one of these types (or nothing), so clients
+// can rely on the shape for a given code:
+//
+//	-32700, -32600, -32602, -32603  DetailErrorData or ValidationErrorData
+//	-32601                          MethodErrorData (a disabled tool's name
+//	                                as the method)
+//	-32602 (unknown tool/prompt)    NotFoundErrorData
+//	-32602 (protocol version)       VersionErrorData
+//	-32002 (resource not found)     NotFoundErrorData
+//	-32003                          ScopeErrorData
+//	-32099                          RateLimitErrorData
+//
+// Over HTTP and SSE the data object also has a requestId field, matching
+// the X-Request-Id header.
+
+// DetailErrorData explains an error in prose, e.g. a JSON decoding error.
+type DetailErrorData struct {
+	Detail string `json:"detail"`
+}
+
+// ValidationErrorData lists every rejected argument of a call.
+type ValidationErrorData struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// FieldError describes why a single argument was rejected.
+type FieldError struct {
+	Property string        `json:"property"`
+	Reason   string        `json:"reason"`
+	Allowed  []interface{} `json:"allowed,omitempty"`
+}
+
+// MethodErrorData names a method the server doesn't offer.
+type MethodErrorData struct {
+	Method           string   `json:"method"`
+	SupportedMethods []string `json:"supportedMethods,omitempty"`
+}
+
+// NotFoundErrorData names the tool, prompt or resource URI that doesn't
+// exist.
+type NotFoundErrorData struct {
+	Name string `json:"name"`
+}
+
+// VersionErrorData lists the protocol versions the server could have used.
+type VersionErrorData struct {
+	Requested string   `json:"requested"`
+	Supported []string `json:"supported"`
+}
+
+// ScopeErrorData lists the scopes a tool needs and those the token lacks.
+type ScopeErrorData struct {
+	Required []string `json:"required"`
+	Missing  []string `json:"missing"`
+}
+
+// RateLimitErrorData says how long to wait before calling again.
+type RateLimitErrorData struct {
+	RetryAfterSeconds int `json:"retryAfterSeconds"`
+}
+
+func newRPCError(id interface{}, code int, message string, data interface{}) JSONRPCResponse {
+	return JSONRPCResponse{
+		JsonRPC: "2.0",
+		ID:      id,
+		Error: &RPCError{
+			Code:    code,
+			Message: message,
+			Data:    data,
+		},
+	}
+}
+
+// newInvalidParams reports params that couldn't be decoded.
+func newInvalidParams(id interface{}, err error) JSONRPCResponse {
+	return newRPCError(id, -32602, "Invalid params", DetailErrorData{Detail: err.Error()})
+}
+
+// newInvalidRequest reports a message that isn't a valid JSON-RPC request.
+func newInvalidRequest(id interface{}, detail string) JSONRPCResponse {
+	var data interface{}
+	if detail != "" {
+		data = DetailErrorData{Detail: detail}
+	}
+	return newRPCError(id, -32600, "Invalid Request", data)
+}
+
+// newValidationError reports arguments that don't satisfy a schema.
+func newValidationError(id interface{}, message string, fields []FieldError) JSONRPCResponse {
+	return newRPCError(id, -32602, message, ValidationErrorData{Fields: fields})
+}
+
+// newMethodNotFound reports an unknown method along with the known ones.
+func newMethodNotFound(id interface{}, method string) JSONRPCResponse {
+	return newRPCError(id, -32601, "Method not found", MethodErrorData{
+		Method:           method,
+		SupportedMethods: supportedMethods(),
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestErrorDataJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		resp JSONRPCResponse
+		want string
+	}{
+		{
+			"detail",
+			newInvalidParams(1, errors.New("bad json")),
+			`{"code":-32602,"message":"Invalid params","data":{"detail":"bad json"}}`,
+		},
+		{
+			"invalid request without detail",
+			newInvalidRequest(nil, ""),
+			`{"code":-32600,"message":"Invalid Request"}`,
+		},
+		{
+			"validation",
+			newValidationError(1, "Invalid arguments", []FieldError{
+				{Property: "rate", Reason: "not allowed", Allowed: []interface{}{0, 5}},
+				{Property: "amount", Reason: "required"},
+			}),
+			`{"code":-32602,"message":"Invalid arguments","data":{"fields":[{"property":"rate","reason":"not allowed","allowed":[0,5]},{"property":"amount","reason":"required"}]}}`,
+		},
+		{
+			"method",
+			newRPCError(1, -32601, "Tool disabled: x", MethodErrorData{Method: "x"}),
+			`{"code":-32601,"message":"Tool disabled: x","data":{"method":"x"}}`,
+		},
+		{
+			"not found",
+			newRPCError(1, -32602, "Unknown tool: x", NotFoundErrorData{Name: "x"}),
+			`{"code":-32602,"message":"Unknown tool: x","data":{"name":"x"}}`,
+		},
+		{
+			"version",
+			newRPCError(1, -32602, "Unsupported protocol version", VersionErrorData{Requested: "2020-01-01", Supported: []string{"2025-03-26"}}),
+			`{"code":-32602,"message":"Unsupported protocol version","data":{"requested":"2020-01-01","supported":["2025-03-26"]}}`,
+		},
+		{
+			"scope",
+			newRPCError(1, -32003, "Insufficient scope", ScopeErrorData{Required: []string{"a", "b"}, Missing: []string{"b"}}),
+			`{"code":-32003,"message":"Insufficient scope","data":{"required":["a","b"],"missing":["b"]}}`,
+		},
+		{
+			"rate limit",
+			newRPCError(1, -32099, "Rate limit exceeded", RateLimitErrorData{RetryAfterSeconds: 3}),
+			`{"code":-32099,"message":"Rate limit exceeded","data":{"retryAfterSeconds":3}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.resp.Error)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got  %s\nwant %s", data, tt.want)
+			}
+		})
+	}
+}
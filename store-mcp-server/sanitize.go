@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+//
+// --------------------
+// Output sanitization
+// --------------------
+//
+
+// Modes for SetSanitizeMode.
+const (
+	sanitizeOff   = "off"
+	sanitizeStrip = "strip"
+	sanitizeFlag  = "flag"
+)
+
+// injectionMarkers are phrases that in store data can only be an attempt to
+// steer the model reading the tool result. They are matched ignoring case.
+var injectionMarkers = []string{
+	"ignore previous instructions",
+	"ignore all previous instructions",
+	"disregard previous instructions",
+	"ignore the above",
+	"system prompt",
+	"you are now",
+	"<|im_start|>",
+	"<|im_end|>",
+	"[inst]",
+}
+
+// SetSanitizeMode chooses how tool results' text is cleaned before it goes
+// out: "off" leaves it alone, "strip" removes control characters, and "flag"
+// also lists prompt-injection phrases it finds in the result's
+// _meta.suspectedInjection.
+func (s *MCPServer) SetSanitizeMode(mode string) error {
+	switch mode {
+	case sanitizeOff, sanitizeStrip, sanitizeFlag:
+		s.sanitize = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown sanitize mode %q (want off, strip or flag)", mode)
+	}
+}
+
+// sanitizeResult returns result with its text cleaned according to the
+// sanitize mode. result itself is left alone, since it may be cached.
+func (s *MCPServer) sanitizeResult(ctx context.Context, tool string, result CallToolResult) CallToolResult {
+	if s.sanitize == "" || s.sanitize == sanitizeOff {
+		return result
+	}
+
+	content := make([]Content, len(result.Content))
+	var suspected []string
+	for i, c := range result.Content {
+		c.Text = stripControl(c.Text)
+		if s.sanitize == sanitizeFlag {
+			suspected = appendMarkers(suspected, c.Text)
+		}
+		content[i] = c
+	}
+	result.Content = content
+
+	if len(suspected) > 0 {
+		slog.WarnContext(ctx, "Possible prompt injection in tool result", "tool", tool, "markers", suspected)
+		meta := make(map[string]interface{}, len(result.Meta)+1)
+		for k, v := range result.Meta {
+			meta[k] = v
+		}
+		meta["suspectedInjection"] = suspected
+		result.Meta = meta
+	}
+	return result
+}
+
+// stripControl removes control characters other than newline and tab, and
+// the bidirectional overrides that can make text read differently from how
+// it is stored. Letters, marks and the zero-width joiners that Indic scripts
+// need are kept.
+func stripControl(text string) string {
+	clean := func(r rune) bool {
+		switch {
+		case r == '\n' || r == '\t':
+			return false
+		case unicode.IsControl(r):
+			return true
+		case r >= '\u202a' && r <= '\u202e', r >= '\u2066' && r <= '\u2069':
+			return true
+		}
+		return false
+	}
+	if strings.IndexFunc(text, clean) < 0 {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if clean(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// appendMarkers adds the injection markers found in text that aren't in
+// found yet.
+func appendMarkers(found []string, text string) []string {
+	lower := strings.ToLower(text)
+	for _, m := range injectionMarkers {
+		if !strings.Contains(lower, m) {
+			continue
+		}
+		seen := false
+		for _, f := range found {
+			seen = seen || f == m
+		}
+		if !seen {
+			found = append(found, m)
+		}
+	}
+	return found
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// --------------------
+// Argument validation
+// --------------------
+//
+
+const reasonMissing = "required property is missing"
+
+// validateArguments checks args against schema: required properties must be
+// present and declared properties must have the declared JSON type, enum
+// value, minimum and pattern, and arrays their item type and minimum
+// length. Extra properties are allowed. patterns holds the compiled Pattern
+// of each property that has one.
+func validateArguments(schema InputSchema, patterns map[string]*regexp.Regexp, args map[string]interface{}) []FieldError {
+	var errs []FieldError
+
+	for _, name := range schema.Required {
+		if _, ok := args[name]; !ok {
+			errs = append(errs, FieldError{Property: name, Reason: reasonMissing})
+		}
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, ok := args[name]
+		if !ok {
+			continue
+		}
+		prop := schema.Properties[name]
+		if !matchesType(prop.Type, value) {
+			errs = append(errs, FieldError{
+				Property: name,
+				Reason:   fmt.Sprintf("expected %s, got %s", prop.Type, jsonTypeOf(value)),
+			})
+			continue
+		}
+		if len(prop.Enum) > 0 && !inEnum(prop.Enum, value) {
+			errs = append(errs, FieldError{
+				Property: name,
+				Reason:   fmt.Sprintf("value %v is not one of the allowed values", value),
+				Allowed:  prop.Enum,
+			})
+		}
+		if n, ok := value.(float64); ok && prop.Minimum != nil && n < *prop.Minimum {
+			errs = append(errs, FieldError{
+				Property: name,
+				Reason:   fmt.Sprintf("value %v is less than the minimum %v", value, *prop.Minimum),
+			})
+		}
+		if items, ok := value.([]interface{}); ok {
+			if prop.MinItems != nil && len(items) < *prop.MinItems {
+				errs = append(errs, FieldError{
+					Property: name,
+					Reason:   fmt.Sprintf("has %d items, fewer than the minimum %d", len(items), *prop.MinItems),
+				})
+			}
+			if prop.Items != nil {
+				for i, item := range items {
+					if !matchesType(prop.Items.Type, item) {
+						errs = append(errs, FieldError{
+							Property: fmt.Sprintf("%s[%d]", name, i),
+							Reason:   fmt.Sprintf("expected %s, got %s", prop.Items.Type, jsonTypeOf(item)),
+						})
+					}
+				}
+			}
+		}
+		if str, ok := value.(string); ok && patterns[name] != nil && !patterns[name].MatchString(str) {
+			errs = append(errs, FieldError{
+				Property: name,
+				Reason:   fmt.Sprintf("value %q does not match the pattern %s", str, prop.Pattern),
+			})
+		}
+	}
+
+	return errs
+}
+
+// argumentErrorMessage summarises errs for the RPC error message, calling out
+// missing required properties by name since that is the most common mistake.
+func argumentErrorMessage(tool string, errs []FieldError) string {
+	var missing []string
+	for _, e := range errs {
+		if e.Reason == reasonMissing {
+			missing = append(missing, e.Property)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf("Missing required arguments for tool %s: %s", tool, strings.Join(missing, ", "))
+	}
+	return "Invalid arguments for tool " + tool
+}
+
+// inEnum reports whether value is one of allowed. The JSON type must match
+// too, so the number 18 is not the string "18". Numeric enum values may be
+// declared as int or float64.
+func inEnum(allowed []interface{}, value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool:
+	default:
+		return false
+	}
+	for _, a := range allowed {
+		if enumValue(a) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// enumValue converts an int enum value to the float64 encoding/json decodes
+// numbers to.
+func enumValue(v interface{}) interface{} {
+	if n, ok := v.(int); ok {
+		return float64(n)
+	}
+	return v
+}
+
+func matchesType(want string, value interface{}) bool {
+	switch want {
+	case "", "any":
+		return true
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	default:
+		return jsonTypeOf(value) == want
+	}
+}
+
+// jsonTypeOf names the JSON type of a value produced by encoding/json.
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInEnum(t *testing.T) {
+	tests := []struct {
+		allowed []interface{}
+		value   interface{}
+		want    bool
+	}{
+		{[]interface{}{0, 5, 18}, 18.0, true},
+		{[]interface{}{0, 5, 18}, 18.5, false},
+		{[]interface{}{0, 5, 18}, "18", false},
+		{[]interface{}{0.5, 1.5}, 1.5, true},
+		{[]interface{}{"a", "b"}, "b", true},
+		{[]interface{}{"a", "b"}, "c", false},
+		{[]interface{}{true}, true, true},
+		{[]interface{}{"a"}, []interface{}{"a"}, false},
+		{[]interface{}{"a"}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := inEnum(tt.allowed, tt.value); got != tt.want {
+			t.Errorf("inEnum(%v, %#v) = %v, want %v", tt.allowed, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterToolRejectsMistypedEnum(t *testing.T) {
+	err := NewMCPServer().RegisterTool(Tool{
+		Name: "t",
+		InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+			"rate": {Type: "number", Enum: []interface{}{"5"}},
+		}},
+	}, func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+		return CallToolResult{}, nil
+	})
+	if err == nil {
+		t.Error("RegisterTool accepted a string enum on a number property")
+	}
+}
+
+func TestPatternProperty(t *testing.T) {
+	noop := func(context.Context, map[string]interface{}) (CallToolResult, error) {
+		return CallToolResult{Content: []Content{{Type: "text", Text: "ok"}}}, nil
+	}
+	schema := func(pattern string) InputSchema {
+		return InputSchema{Type: "object", Properties: map[string]Property{"code": {Type: "string", Pattern: pattern}}}
+	}
+
+	if err := NewMCPServer().RegisterTool(Tool{Name: "bad", InputSchema: schema(`^[0-9`)}, noop); err == nil {
+		t.Error("RegisterTool accepted a pattern that doesn't compile")
+	}
+
+	s := NewMCPServer()
+	if err := s.RegisterTool(Tool{Name: "coded", InputSchema: schema(`^[A-Z]{3}$`)}, noop); err != nil {
+		t.Fatal(err)
+	}
+	ctx := initialized(t, s)
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{"ABC", true},
+		{"abc", false},
+		{"ABCD", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			resp := callTool(t, s, ctx, "coded", map[string]interface{}{"code": tt.code})
+			if ok := resp.Error == nil; ok != tt.ok {
+				t.Errorf("accepted = %v, want %v (error %+v)", ok, tt.ok, resp.Error)
+			}
+		})
+	}
+}
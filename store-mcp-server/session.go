@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//
+// --------------------
+// Sessions
+// --------------------
+//
+
+// Session is the per-client protocol state. Over HTTP it is identified by
+// the Mcp-Session-Id header; stdio and SSE connections each own one.
+//
+// All fields except ID and logLevel are guarded by the server's mutex.
+type Session struct {
+	ID              string
+	state           lifecycleState
+	protocolVersion string
+	inflight        map[interface{}]context.CancelFunc
+	lastSeen        time.Time
+
+	// logLevel is the minimum level of log records sent to this client,
+	// set with logging/setLevel; the zero value is info.
+	logLevel slog.LevelVar
+}
+
+func newSession() *Session {
+	return &Session{
+		ID:       newRequestID(),
+		inflight: make(map[interface{}]context.CancelFunc),
+	}
+}
+
+func withSession(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, sess)
+}
+
+// sessionFromContext returns the session a message arrived on, if any.
+func sessionFromContext(ctx context.Context) *Session {
+	sess, _ := ctx.Value(sessionContextKey).(*Session)
+	return sess
+}
+
+// addSession makes sess reachable by its ID for later HTTP requests.
+func (s *MCPServer) addSession(sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.lastSeen = time.Now()
+	s.sessions[sess.ID] = sess
+}
+
+// lookupSession finds a session and marks it active.
+func (s *MCPServer) lookupSession(id string) (*Session, bool) {
+	if id == "" {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if ok {
+		sess.lastSeen = time.Now()
+	}
+	return sess, ok
+}
+
+// removeSession ends a session, aborting any tool calls still running on it.
+func (s *MCPServer) removeSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	s.dropSession(sess)
+	return true
+}
+
+// dropSession must be called with s.mu held.
+func (s *MCPServer) dropSession(sess *Session) {
+	delete(s.sessions, sess.ID)
+	for _, cancel := range sess.inflight {
+		cancel()
+	}
+}
+
+// expireSessions removes sessions idle for longer than ttl and reports how
+// many it removed.
+func (s *MCPServer) expireSessions(now time.Time, ttl time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) > ttl {
+			s.dropSession(sess)
+			n++
+		}
+	}
+	return n
+}
+
+// reapSessions expires idle sessions until ctx is cancelled. Clients that
+// vanish without a DELETE would otherwise leave their sessions behind.
+func (s *MCPServer) reapSessions(ctx context.Context, ttl time.Duration) {
+	interval := time.Minute
+	if ttl < interval {
+		interval = ttl
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := s.expireSessions(now, ttl); n > 0 {
+				log.Printf("Expired %d idle sessions", n)
+			}
+		}
+	}
+}
+
+// sessionIDPattern accepts the UUIDs newSession hands out and other opaque
+// tokens made of URL-safe characters. Anything else never names a session,
+// and could smuggle control characters into the logs.
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{1,128}$`)
+
+// checkSessionID rejects requests whose Mcp-Session-Id header is malformed
+// with a JSON-RPC Invalid Request and 400, before the session map is
+// consulted. Surrounding whitespace is stripped; a blank header counts as
+// no session.
+func checkSessionID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := r.Header.Values("Mcp-Session-Id")
+		if len(values) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := strings.TrimSpace(values[0])
+		if id == "" && len(values) == 1 {
+			// Same as no session
+			r.Header.Del("Mcp-Session-Id")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(values) > 1 || !sessionIDPattern.MatchString(id) {
+			w.Header().Set("Content-Type", "application/json")
+			writeRPC(w, requestInfoFromContext(r.Context()), http.StatusBadRequest,
+				newInvalidRequest(nil, "malformed Mcp-Session-Id header"))
+			return
+		}
+		r.Header.Set("Mcp-Session-Id", id)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpireSessions(t *testing.T) {
+	const ttl = 30 * time.Minute
+	tests := []struct {
+		name    string
+		idle    time.Duration
+		expired bool
+	}{
+		{"fresh", 0, false},
+		{"just under ttl", ttl - time.Second, false},
+		{"idle past ttl", ttl + time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			sess := newSession()
+			s.addSession(sess)
+
+			if n := s.expireSessions(time.Now().Add(tt.idle), ttl); (n == 1) != tt.expired {
+				t.Errorf("expired %d sessions, want expired = %v", n, tt.expired)
+			}
+			if _, ok := s.lookupSession(sess.ID); ok == tt.expired {
+				t.Errorf("session still known = %v, want %v", ok, !tt.expired)
+			}
+		})
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	s := NewMCPServer()
+	sess := newSession()
+	s.addSession(sess)
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"known", sess.ID, http.StatusNoContent},
+		{"already ended", sess.ID, http.StatusNotFound},
+		{"unknown", "nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/mcp", nil)
+			req.Header.Set("Mcp-Session-Id", tt.id)
+			rec := httptest.NewRecorder()
+			s.handleMCPRequest(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSessionID(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		status   int
+		passedOn string // header value seen by the next handler
+	}{
+		{"none", nil, http.StatusOK, ""},
+		{"uuid", []string{"0f8fad5b-d9cb-469f-a165-70867728950e"}, http.StatusOK, "0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{"opaque token", []string{"abc.DEF_123~x"}, http.StatusOK, "abc.DEF_123~x"},
+		{"surrounding space", []string{"  abc  "}, http.StatusOK, "abc"},
+		{"blank", []string{"   "}, http.StatusOK, ""},
+		{"newline", []string{"abc\ninjected"}, http.StatusBadRequest, ""},
+		{"space inside", []string{"abc def"}, http.StatusBadRequest, ""},
+		{"slash", []string{"../etc"}, http.StatusBadRequest, ""},
+		{"too long", []string{strings.Repeat("a", 129)}, http.StatusBadRequest, ""},
+		{"repeated header", []string{"abc", "def"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := checkSessionID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = r.Header.Get("Mcp-Session-Id")
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			for _, v := range tt.values {
+				req.Header["Mcp-Session-Id"] = append(req.Header["Mcp-Session-Id"], v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				if seen != tt.passedOn {
+					t.Errorf("next saw %q, want %q", seen, tt.passedOn)
+				}
+				return
+			}
+			var reply JSONRPCResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Error == nil || reply.Error.Code != -32600 {
+				t.Errorf("error = %+v, want -32600", reply.Error)
+			}
+		})
+	}
+}
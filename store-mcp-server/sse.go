@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//
+// --------------------
+// HTTP+SSE transport
+// --------------------
+//
+
+// sseKeepalive is how often an idle stream gets a comment line, so proxies
+// don't time out the connection.
+const sseKeepalive = 15 * time.Second
+
+// sseChunkBytes is roughly how much content one "chunk" event carries.
+// Tool results no larger than this are sent whole.
+const sseChunkBytes = 256 << 10
+
+// sseSession is one open event stream and the MCP session it carries.
+// Replies to messages POSTed for the session are queued on events.
+type sseSession struct {
+	*Session
+	events chan sseEvent
+	done   chan struct{}
+	// chunked is set when the client opened the stream with ?chunked=1 and
+	// so can reassemble tool results split by pushResult.
+	chunked bool
+}
+
+// sseEvent is one queued event: its name and JSON payload.
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// sseChunk is part of a tool result's content, sent ahead of the reply to
+// request ID.
+type sseChunk struct {
+	ID      interface{}       `json:"id"`
+	Index   int               `json:"index"`
+	Content []json.RawMessage `json:"content"`
+}
+
+// SSEHub serves the HTTP+SSE transport: clients hold GET /mcp/sse open and
+// POST their messages to the endpoint announced on it.
+type SSEHub struct {
+	server   *MCPServer
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+func NewSSEHub(server *MCPServer) *SSEHub {
+	return &SSEHub{
+		server:   server,
+		sessions: make(map[string]*sseSession),
+	}
+}
+
+// handleStream opens an event stream and announces where to POST messages.
+// The session lives until the client disconnects. Clients that open it with
+// ?chunked=1 get large tool results split across events (see pushResult).
+func (h *SSEHub) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("sse: clearing write deadline: %v", err)
+	}
+
+	sess := &sseSession{
+		Session: newSession(),
+		events:  make(chan sseEvent, 16),
+		done:    make(chan struct{}),
+		chunked: r.URL.Query().Get("chunked") == "1",
+	}
+	h.mu.Lock()
+	h.sessions[sess.ID] = sess
+	h.mu.Unlock()
+	defer h.server.subscribe(sess.Session, func(n JSONRPCNotification) { sess.push(n) })()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, sess.ID)
+		h.mu.Unlock()
+		close(sess.done)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "event: endpoint\ndata: /mcp/sse/message?sessionId=%s\n\n", sess.ID)
+	if err := rc.Flush(); err != nil {
+		log.Printf("sse: streaming unsupported: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepalive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-sess.events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handleMessage accepts a client message for an open stream. The reply, if
+// any, is delivered as an event on that stream; the POST itself only gets
+// 202 Accepted.
+func (h *SSEHub) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	sess, ok := h.sessions[r.URL.Query().Get("sessionId")]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info := requestInfoFromContext(r.Context())
+	if info != nil {
+		info.RPCMethod = rpcMethodOf(body)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	ctx := withClientAddr(withSession(r.Context(), sess.Session), r)
+	ctx = withNotifier(ctx, func(n JSONRPCNotification) { sess.push(n) })
+	resp, ok := h.server.handleMessage(ctx, body)
+	if !ok {
+		return
+	}
+	sess.pushResult(annotateErrors(resp, info))
+}
+
+// push queues a message for the stream. If the client has gone away the
+// message is dropped, since nobody is left to read it.
+func (sess *sseSession) push(msg interface{}) {
+	sess.pushEvent("message", msg)
+}
+
+func (sess *sseSession) pushEvent(name string, msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("sse: encoding %s: %v", name, err)
+		return
+	}
+
+	select {
+	case sess.events <- sseEvent{name: name, data: data}:
+	case <-sess.done:
+	}
+}
+
+// pushResult queues a reply, splitting a large tool result into "chunk"
+// events if the client opted in. Each chunk holds whole content blocks for
+// the request id, numbered from 0; the closing "message" event is the usual
+// reply with the remaining blocks and _meta.chunks counting the chunks
+// before it. Clients rebuild the result by concatenating the chunks' content
+// in index order, then the reply's own.
+//
+// Blocks are encoded and queued one chunk at a time, so a slow client holds
+// back the rest of the encoding instead of the whole result being buffered
+// as one event.
+func (sess *sseSession) pushResult(resp interface{}) {
+	r, ok := resp.(JSONRPCResponse)
+	if !ok || !sess.chunked {
+		sess.push(resp)
+		return
+	}
+	result, ok := r.Result.(CallToolResult)
+	if !ok {
+		sess.push(resp)
+		return
+	}
+
+	var pending []json.RawMessage
+	size, chunks := 0, 0
+	for _, c := range result.Content {
+		block, err := json.Marshal(c)
+		if err != nil {
+			log.Printf("sse: encoding content: %v", err)
+			sess.push(newRPCError(r.ID, -32603, "Internal error", nil))
+			return
+		}
+		if len(pending) > 0 && size+len(block) > sseChunkBytes {
+			sess.pushEvent("chunk", sseChunk{ID: r.ID, Index: chunks, Content: pending})
+			pending, size = nil, 0
+			chunks++
+		}
+		pending = append(pending, block)
+		size += len(block)
+	}
+	if chunks == 0 {
+		sess.push(resp)
+		return
+	}
+
+	// Meta may be shared with the result cache, so copy before adding to it
+	meta := make(map[string]interface{}, len(result.Meta)+1)
+	for k, v := range result.Meta {
+		meta[k] = v
+	}
+	meta["chunks"] = chunks
+	result.Content = result.Content[len(result.Content)-len(pending):]
+	result.Meta = meta
+	r.Result = result
+	sess.push(r)
+}
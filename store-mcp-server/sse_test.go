@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// drainEvents returns the events queued on sess so far.
+func drainEvents(sess *sseSession) []sseEvent {
+	var events []sseEvent
+	for {
+		select {
+		case ev := <-sess.events:
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func TestPushResultChunks(t *testing.T) {
+	block := strings.Repeat("x", sseChunkBytes/3)
+	large := make([]Content, 7)
+	for i := range large {
+		large[i] = Content{Type: "text", Text: string(rune('a'+i)) + block}
+	}
+
+	tests := []struct {
+		name       string
+		chunked    bool
+		content    []Content
+		wantChunks int
+	}{
+		{"client didn't opt in", false, large, 0},
+		{"small result", true, []Content{{Type: "text", Text: "ok"}}, 0},
+		{"one oversized block", true, []Content{{Type: "text", Text: strings.Repeat("y", 2*sseChunkBytes)}}, 0},
+		{"large result", true, large, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &sseSession{
+				Session: newSession(),
+				events:  make(chan sseEvent, 16),
+				done:    make(chan struct{}),
+				chunked: tt.chunked,
+			}
+			sess.pushResult(JSONRPCResponse{JsonRPC: "2.0", ID: "r1", Result: CallToolResult{Content: tt.content}})
+
+			events := drainEvents(sess)
+			if len(events) != tt.wantChunks+1 {
+				t.Fatalf("got %d events, want %d chunks and the reply", len(events), tt.wantChunks)
+			}
+
+			var texts []string
+			for i, ev := range events[:tt.wantChunks] {
+				var chunk struct {
+					ID      string    `json:"id"`
+					Index   int       `json:"index"`
+					Content []Content `json:"content"`
+				}
+				if err := json.Unmarshal(ev.data, &chunk); err != nil {
+					t.Fatal(err)
+				}
+				if ev.name != "chunk" || chunk.ID != "r1" || chunk.Index != i {
+					t.Fatalf("event %d = %s id %s index %d, want chunk r1 %d", i, ev.name, chunk.ID, chunk.Index, i)
+				}
+				for _, c := range chunk.Content {
+					texts = append(texts, c.Text)
+				}
+			}
+
+			last := events[len(events)-1]
+			var reply struct {
+				ID     string `json:"id"`
+				Result struct {
+					Content []Content              `json:"content"`
+					Meta    map[string]interface{} `json:"_meta"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(last.data, &reply); err != nil {
+				t.Fatal(err)
+			}
+			if last.name != "message" || reply.ID != "r1" {
+				t.Fatalf("last event = %s for id %s, want the reply to r1", last.name, reply.ID)
+			}
+			if tt.wantChunks > 0 && reply.Result.Meta["chunks"] != float64(tt.wantChunks) {
+				t.Errorf("_meta.chunks = %v, want %d", reply.Result.Meta["chunks"], tt.wantChunks)
+			}
+			for _, c := range reply.Result.Content {
+				texts = append(texts, c.Text)
+			}
+
+			// Reassembled in order, the blocks are the original result
+			if len(texts) != len(tt.content) {
+				t.Fatalf("reassembled %d blocks, want %d", len(texts), len(tt.content))
+			}
+			for i, c := range tt.content {
+				if texts[i] != c.Text {
+					t.Errorf("block %d differs after reassembly", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+//
+// --------------------
+// Stats
+// --------------------
+//
+
+// Stats counts requests for /stats, a lightweight alternative to the
+// Prometheus metrics that is always on. The per-method map is fixed at
+// creation, so counting needs no lock.
+type Stats struct {
+	start    time.Time
+	total    atomic.Int64
+	byMethod map[string]*atomic.Int64
+}
+
+func NewStats() *Stats {
+	st := &Stats{
+		start:    time.Now(),
+		byMethod: map[string]*atomic.Int64{"unknown": new(atomic.Int64)},
+	}
+	for m := range knownMethods {
+		st.byMethod[m] = new(atomic.Int64)
+	}
+	return st
+}
+
+// observeRequest counts one request; method must be a knownMethods entry
+// or "unknown".
+func (st *Stats) observeRequest(method string) {
+	st.total.Add(1)
+	if n, ok := st.byMethod[method]; ok {
+		n.Add(1)
+	}
+}
+
+// StatsSnapshot is the /stats response body. Methods that haven't been
+// called are left out.
+type StatsSnapshot struct {
+	StartedAt      time.Time        `json:"startedAt"`
+	UptimeSeconds  float64          `json:"uptimeSeconds"`
+	Requests       int64            `json:"requests"`
+	Methods        map[string]int64 `json:"methods"`
+	ActiveSessions int              `json:"activeSessions"`
+}
+
+// stats reports the counters along with the sessions open right now: HTTP
+// sessions plus the stdio, SSE and WebSocket connections.
+func (s *MCPServer) stats() StatsSnapshot {
+	snap := StatsSnapshot{
+		StartedAt:     s.counters.start.UTC(),
+		UptimeSeconds: time.Since(s.counters.start).Seconds(),
+		Requests:      s.counters.total.Load(),
+		Methods:       make(map[string]int64),
+	}
+	for m, n := range s.counters.byMethod {
+		if v := n.Load(); v > 0 {
+			snap.Methods[m] = v
+		}
+	}
+
+	s.mu.RLock()
+	snap.ActiveSessions = len(s.sessions) + len(s.subscribers)
+	s.mu.RUnlock()
+	return snap
+}
+
+func (s *MCPServer) handleStats(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.stats())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	const initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+	tests := []struct {
+		name     string
+		bodies   []string
+		requests int64
+		methods  map[string]int64
+		sessions int
+	}{
+		{"fresh server", nil, 0, map[string]int64{}, 0},
+		{"initialize", []string{initialize}, 1, map[string]int64{"initialize": 1}, 1},
+		{
+			"several methods",
+			[]string{
+				initialize,
+				`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+				`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+				`{"jsonrpc":"2.0","id":4,"method":"no/such"}`,
+			},
+			4, map[string]int64{"initialize": 1, "ping": 2, "unknown": 1}, 1,
+		},
+		{
+			"batch counts each request",
+			[]string{initialize, `[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","id":3,"method":"tools/list"}]`},
+			3, map[string]int64{"initialize": 1, "ping": 1, "tools/list": 1}, 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			sessionID := ""
+			for _, body := range tt.bodies {
+				rec := postMCP(s, sessionID, body)
+				if id := rec.Header().Get("Mcp-Session-Id"); id != "" {
+					sessionID = id
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			s.handleStats(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var got StatsSnapshot
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			if got.Requests != tt.requests || !reflect.DeepEqual(got.Methods, tt.methods) || got.ActiveSessions != tt.sessions {
+				t.Errorf("stats = %d requests %v, %d sessions; want %d %v, %d",
+					got.Requests, got.Methods, got.ActiveSessions, tt.requests, tt.methods, tt.sessions)
+			}
+			if got.StartedAt.IsZero() || got.UptimeSeconds < 0 {
+				t.Errorf("startedAt %v, uptime %v", got.StartedAt, got.UptimeSeconds)
+			}
+		})
+	}
+}
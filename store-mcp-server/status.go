@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+//
+// --------------------
+// Store reachability
+// --------------------
+//
+
+// StoreStatus is the result of check_store_status.
+type StoreStatus struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Reachable  bool   `json:"reachable"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Status     string `json:"status,omitempty"`
+	LatencyMS  int64  `json:"latencyMs,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// statusTool sends a HEAD request to a store's URL. Any HTTP response, even
+// an error status, counts as reachable; the status is reported alongside.
+// timeout bounds each check, retries included, so a dead store can't hold
+// up the tool call.
+func (c *StoreCatalog) statusTool(client *http.Client, timeout time.Duration) ToolHandler {
+	return func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+		name, _ := args["name"].(string)
+
+		store, ok := c.Find(name)
+		if !ok {
+			return CallToolResult{
+				Content: []Content{{Type: "text", Text: "store not found: " + name}},
+				IsError: true,
+			}, nil
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		status := checkURL(checkCtx, client, store.URL)
+		status.Name = store.Name
+
+		data, err := json.Marshal(status)
+		if err != nil {
+			return CallToolResult{}, err
+		}
+		return CallToolResult{
+			Content: []Content{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+}
+
+func checkURL(ctx context.Context, client *http.Client, url string) StoreStatus {
+	status := StoreStatus{URL: url}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	req.Header.Set("User-Agent", defaultServerName+"/"+Version)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	resp.Body.Close()
+
+	status.Reachable = true
+	status.StatusCode = resp.StatusCode
+	status.Status = http.StatusText(resp.StatusCode)
+	status.LatencyMS = time.Since(start).Milliseconds()
+	return status
+}
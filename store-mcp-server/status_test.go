@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusTool(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+	}))
+	defer up.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	hung := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(hung)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	catalog, err := ParseStoreCatalog([]byte(fmt.Sprintf(`[
+		{"name": "Up", "url": %q, "category": "Test"},
+		{"name": "Failing", "url": %q, "category": "Test"},
+		{"name": "Slow", "url": %q, "category": "Test"},
+		{"name": "Down", "url": %q, "category": "Test"}
+	]`, up.URL, failing.URL, slow.URL, down.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := (&Config{CasdoorTimeout: 10 * time.Second, CasdoorMaxAttempts: 1}).casdoorClient()
+	tool := catalog.statusTool(client, 200*time.Millisecond)
+
+	tests := []struct {
+		store         string
+		wantError     bool
+		wantReachable bool
+		wantCode      int
+	}{
+		{"Up", false, true, http.StatusOK},
+		{"Failing", false, true, http.StatusForbidden},
+		{"Slow", false, false, 0},
+		{"Down", false, false, 0},
+		{"Nowhere", true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.store, func(t *testing.T) {
+			start := time.Now()
+			result, err := tool(context.Background(), map[string]interface{}{"name": tt.store})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("check took %s despite the 200ms timeout", elapsed)
+			}
+			if result.IsError != tt.wantError {
+				t.Fatalf("IsError = %v, want %v", result.IsError, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			var status StoreStatus
+			if err := json.Unmarshal([]byte(result.Content[0].Text), &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Reachable != tt.wantReachable || status.StatusCode != tt.wantCode {
+				t.Errorf("status = %+v, want reachable %v with code %d", status, tt.wantReachable, tt.wantCode)
+			}
+			if !status.Reachable && status.Error == "" {
+				t.Error("unreachable store reported without an error")
+			}
+		})
+	}
+}
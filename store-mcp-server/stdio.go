@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// --------------------
+// stdio transport
+// --------------------
+//
+
+// activity records when a peer was last heard from.
+type activity struct {
+	last atomic.Int64 // unix nanoseconds
+}
+
+func (a *activity) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+// LastSeen returns when the peer last sent a message, or the zero time if it
+// never has.
+func (a *activity) LastSeen() time.Time {
+	n := a.last.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// stdioConn is one client speaking JSON-RPC over a pair of streams.
+type stdioConn struct {
+	server *MCPServer
+	in     *bufio.Reader
+	out    io.Writer
+	seen   activity
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+	// framed is set once the client sends a Content-Length framed message;
+	// replies are framed the same way from then on.
+	framed atomic.Bool
+
+	pingSeq      atomic.Int64
+	pendingPings atomic.Int64
+
+	calls sync.WaitGroup
+}
+
+// serveStdio reads JSON-RPC messages from in and writes responses to out. It
+// returns when in is exhausted.
+//
+// Messages are newline-delimited JSON, or framed LSP-style with a
+// Content-Length header; a message is framed only when its first line is
+// that header. Responses use newlines until the client sends a framed
+// message. Bad framing gets a parse error reply rather than ending the loop.
+//
+// With a non-zero pingInterval the server pings the client whenever it has
+// been silent that long, and logs a warning once pings go unanswered.
+func (s *MCPServer) serveStdio(in io.Reader, out io.Writer, pingInterval time.Duration) error {
+	c := &stdioConn{
+		server: s,
+		in:     bufio.NewReader(in),
+		out:    out,
+		enc:    json.NewEncoder(out),
+	}
+	c.seen.touch()
+
+	if pingInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go c.keepalive(pingInterval, done)
+	}
+
+	return c.serve()
+}
+
+func (c *stdioConn) serve() error {
+	// Let running tool calls write their responses before we return
+	defer c.calls.Wait()
+
+	// The whole connection is one session
+	sess := newSession()
+	notify := func(n JSONRPCNotification) { c.send(n) }
+	ctx := withSession(context.Background(), sess)
+	ctx = withNotifier(ctx, notify)
+	defer c.server.subscribe(sess, notify)()
+
+	for {
+		line, err := c.readMessage()
+
+		if len(line) > 0 {
+			c.seen.touch()
+			if isResponse(line) {
+				// Replies to our own pings; any answer proves liveness
+				c.pendingPings.Store(0)
+			} else if isToolCall(line) {
+				// Run in the background so a later notifications/cancelled
+				// can be read and reach it
+				c.calls.Add(1)
+				go func(msg []byte) {
+					defer c.calls.Done()
+					if resp, ok := c.server.handleMessage(ctx, msg); ok {
+						c.send(resp)
+					}
+				}(line)
+			} else if resp, ok := c.server.handleMessage(ctx, line); ok {
+				if encErr := c.send(resp); encErr != nil {
+					return encErr
+				}
+			}
+		}
+
+		var frameErr *frameError
+		switch {
+		case errors.As(err, &frameErr):
+			log.Print(err)
+			reply := c.server.sendError(nil, -32700, "Parse error", DetailErrorData{Detail: frameErr.Error()})
+			if encErr := c.send(reply); encErr != nil {
+				return encErr
+			}
+		case err == io.EOF:
+			return nil
+		case err == io.ErrUnexpectedEOF:
+			log.Print("stdio: input ended part way through a message")
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// maxFramedMessage bounds the Content-Length a client may announce, so a
+// bad header can't make us allocate without limit. Larger messages are
+// skipped.
+const maxFramedMessage = 16 << 20
+
+// readMessage returns the next message in either framing. A line that ends
+// at EOF is returned along with io.EOF. Only a Content-Length header starts
+// a framed message; any other line is passed on as it is, so stray text gets
+// a parse error reply like any other malformed JSON.
+func (c *stdioConn) readMessage() ([]byte, error) {
+	// Skip blank lines and whitespace between messages
+	for {
+		b, err := c.in.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if !isSpace(b[0]) {
+			break
+		}
+		c.in.ReadByte()
+	}
+
+	line, err := c.in.ReadBytes('\n')
+	if !isContentLength(line) {
+		return bytes.TrimSpace(line), err
+	}
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	c.framed.Store(true)
+	return c.readFramed(string(line))
+}
+
+func isContentLength(line []byte) bool {
+	name, _, ok := bytes.Cut(line, []byte(":"))
+	return ok && strings.EqualFold(strings.TrimSpace(string(name)), "Content-Length")
+}
+
+// frameError is a framing mistake the connection recovers from: the frame
+// is answered with a parse error and reading carries on after it.
+type frameError struct {
+	msg string
+}
+
+func (e *frameError) Error() string {
+	return "stdio: " + e.msg
+}
+
+// readFramed reads the header lines after first up to a blank line, then
+// the number of bytes Content-Length gives. Other headers, like
+// Content-Type, are ignored.
+func (c *stdioConn) readFramed(first string) ([]byte, error) {
+	length := -1
+	var problem string
+	for line := first; ; {
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch {
+		case !ok:
+			problem = fmt.Sprintf("malformed header %q", line)
+		case strings.EqualFold(strings.TrimSpace(name), "Content-Length"):
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				problem = fmt.Sprintf("invalid Content-Length %q", value)
+			} else {
+				length = n
+			}
+		}
+
+		var err error
+		if line, err = c.in.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+
+	if problem == "" && length > maxFramedMessage {
+		// The length is known, so skip the body and stay in step
+		if _, err := io.CopyN(io.Discard, c.in, int64(length)); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		problem = fmt.Sprintf("message of %d bytes exceeds the %d byte limit", length, maxFramedMessage)
+	}
+	if problem != "" {
+		return nil, &frameError{msg: problem}
+	}
+
+	// ReadFull keeps reading until the body has arrived, however the pipe
+	// splits it
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.in, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// send writes one message. It is safe to call from multiple goroutines.
+func (c *stdioConn) send(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if !c.framed.Load() {
+		return c.enc.Encode(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.out.Write(data)
+	return err
+}
+
+// keepalive pings the client after each idle interval until done is closed.
+func (c *stdioConn) keepalive(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		idle := time.Since(c.seen.LastSeen())
+		if idle < interval {
+			continue
+		}
+		if c.pendingPings.Load() >= 2 {
+			log.Printf("stdio client unresponsive: last message %s ago", idle.Round(time.Second))
+		}
+
+		ping := JSONRPCRequest{
+			JsonRPC: "2.0",
+			ID:      fmt.Sprintf("server-ping-%d", c.pingSeq.Add(1)),
+			Method:  "ping",
+		}
+		if err := c.send(ping); err != nil {
+			return
+		}
+		c.pendingPings.Add(1)
+	}
+}
+
+// isResponse reports whether raw is a JSON-RPC response (it has a result or
+// error but no method) rather than a request or notification.
+func isResponse(raw []byte) bool {
+	var peek struct {
+		Method *string          `json:"method"`
+		Result json.RawMessage  `json:"result"`
+		Error  *json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &peek); err != nil {
+		return false
+	}
+	return peek.Method == nil && (peek.Result != nil || peek.Error != nil)
+}
+
+// isToolCall reports whether raw is a single tools/call request.
+func isToolCall(raw []byte) bool {
+	var peek struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(raw, &peek); err != nil {
+		return false
+	}
+	return peek.Method == "tools/call"
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readReplies decodes every message the server wrote, in either framing, and
+// reports whether they were Content-Length framed.
+func readReplies(t *testing.T, out []byte) ([]JSONRPCResponse, bool) {
+	t.Helper()
+	r := bufio.NewReader(bytes.NewReader(out))
+	framed := bytes.HasPrefix(out, []byte("Content-Length:"))
+	var replies []JSONRPCResponse
+	for {
+		var body []byte
+		if framed {
+			header, err := r.ReadString('\n')
+			if err == io.EOF {
+				break
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+			if err != nil {
+				t.Fatalf("bad reply header %q", header)
+			}
+			r.ReadString('\n')
+			body = make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				t.Fatalf("short reply body: %v", err)
+			}
+		} else {
+			line, err := r.ReadBytes('\n')
+			if err == io.EOF {
+				break
+			}
+			body = line
+		}
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("reply %q: %v", body, err)
+		}
+		replies = append(replies, resp)
+	}
+	return replies, framed
+}
+
+// replySummary renders each reply as "id:ok" or "id:code".
+func replySummary(replies []JSONRPCResponse) []string {
+	var got []string
+	for _, r := range replies {
+		status := "ok"
+		if r.Error != nil {
+			status = strconv.Itoa(r.Error.Code)
+		}
+		got = append(got, fmt.Sprintf("%v:%s", r.ID, status))
+	}
+	return got
+}
+
+func TestServeStdioFraming(t *testing.T) {
+	ping := func(id int) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"ping"}`, id)
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		oneByte    bool
+		want       []string
+		wantFramed bool
+	}{
+		{
+			name:  "newline delimited",
+			input: ping(1) + "\n\n" + ping(2) + "\n",
+			want:  []string{"1:ok", "2:ok"},
+		},
+		{
+			name:  "last line without newline",
+			input: ping(1),
+			want:  []string{"1:ok"},
+		},
+		{
+			name:       "content-length framed",
+			input:      frame(ping(1)) + frame(ping(2)),
+			want:       []string{"1:ok", "2:ok"},
+			wantFramed: true,
+		},
+		{
+			name:       "header case and extra headers",
+			input:      "content-length: " + strconv.Itoa(len(ping(1))) + "\r\nContent-Type: application/json\r\n\r\n" + ping(1),
+			want:       []string{"1:ok"},
+			wantFramed: true,
+		},
+		{
+			name:       "body split across reads",
+			input:      frame(ping(1)) + frame(ping(2)),
+			oneByte:    true,
+			want:       []string{"1:ok", "2:ok"},
+			wantFramed: true,
+		},
+		{
+			name:  "stray line gets a parse error",
+			input: "garbage\n" + ping(1) + "\n",
+			want:  []string{"<nil>:-32700", "1:ok"},
+		},
+		{
+			name:  "header-like line without a colon",
+			input: "Content-Length 12\n" + ping(1) + "\n",
+			want:  []string{"<nil>:-32700", "1:ok"},
+		},
+		{
+			name:       "invalid content-length recovers",
+			input:      "Content-Length: abc\r\n\r\n" + frame(ping(1)),
+			want:       []string{"<nil>:-32700", "1:ok"},
+			wantFramed: true,
+		},
+		{
+			name:       "malformed header recovers",
+			input:      "Content-Length: 5\r\nbogus\r\n\r\n" + frame(ping(1)),
+			want:       []string{"<nil>:-32700", "1:ok"},
+			wantFramed: true,
+		},
+		{
+			name:       "truncated body",
+			input:      frame(ping(1)) + "Content-Length: 100\r\n\r\n{",
+			want:       []string{"1:ok"},
+			wantFramed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in io.Reader = strings.NewReader(tt.input)
+			if tt.oneByte {
+				in = iotest.OneByteReader(in)
+			}
+			var out bytes.Buffer
+			if err := NewMCPServer().serveStdio(in, &out, 0); err != nil {
+				t.Fatalf("serveStdio: %v", err)
+			}
+
+			replies, framed := readReplies(t, out.Bytes())
+			if framed != tt.wantFramed {
+				t.Errorf("framed replies = %v, want %v; output %q", framed, tt.wantFramed, out.String())
+			}
+			got := replySummary(replies)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("replies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name        string
+		initialized bool
+		raw         string
+		reply       bool
+	}{
+		{"before initialize", false, `{"jsonrpc":"2.0","id":1,"method":"ping"}`, true},
+		{"after initialize", true, `{"jsonrpc":"2.0","id":1,"method":"ping"}`, true},
+		{"as notification", true, `{"jsonrpc":"2.0","method":"ping"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer()
+			ctx := withSession(context.Background(), newSession())
+			if tt.initialized {
+				ctx = initialized(t, s)
+			}
+			resp, ok := s.handleMessage(ctx, []byte(tt.raw))
+			if ok != tt.reply {
+				t.Fatalf("reply sent = %v, want %v", ok, tt.reply)
+			}
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `{"jsonrpc":"2.0","id":1,"result":{}}`; string(data) != want {
+				t.Errorf("reply = %s, want %s", data, want)
+			}
+		})
+	}
+}
+
+// An idle stdio client is pinged by the server.
+func TestStdioKeepalive(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- NewMCPServer().serveStdio(inR, outW, 20*time.Millisecond) }()
+
+	var ping JSONRPCRequest
+	if err := json.NewDecoder(outR).Decode(&ping); err != nil {
+		t.Fatalf("reading ping: %v", err)
+	}
+	if ping.Method != "ping" || ping.ID == nil {
+		t.Fatalf("server sent %+v, want a ping request", ping)
+	}
+
+	// Keep draining so further pings can't block the server
+	go io.Copy(io.Discard, outR)
+	fmt.Fprintf(inW, `{"jsonrpc":"2.0","id":%q,"result":{}}`+"\n", ping.ID)
+	inW.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serveStdio: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveStdio didn't return after input closed")
+	}
+}
+
+// stdin closing only means no more requests: a piped client writes its
+// calls and closes, and still gets every reply.
+func TestStdioCallsFinishAfterInputEnds(t *testing.T) {
+	s := NewMCPServer()
+	err := s.RegisterTool(Tool{Name: "nap", InputSchema: InputSchema{Type: "object"}},
+		func(ctx context.Context, _ map[string]interface{}) (CallToolResult, error) {
+			select {
+			case <-time.After(50 * time.Millisecond):
+				return CallToolResult{Content: []Content{{Type: "text", Text: "rested"}}}, nil
+			case <-ctx.Done():
+				return CallToolResult{}, ctx.Err()
+			}
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"nap"}}`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	if err := s.serveStdio(strings.NewReader(input), &out, 0); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&out)
+	var last struct {
+		ID     json.Number    `json:"id"`
+		Result CallToolResult `json:"result"`
+	}
+	for dec.More() {
+		if err := dec.Decode(&last); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last.ID != "2" || last.Result.IsError || len(last.Result.Content) == 0 || last.Result.Content[0].Text != "rested" {
+		t.Errorf("last reply = %+v, want the finished tools/call", last)
+	}
+}
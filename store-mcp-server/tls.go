@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+//
+// --------------------
+// TLS
+// --------------------
+//
+
+// tlsConfig loads the certificate named by -tls-cert and -tls-key. It
+// returns nil when neither is set, meaning serve plain HTTP.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c.TLSCert == "" && c.TLSKey == "" {
+		return nil, nil
+	}
+	if c.TLSCert == "" || c.TLSKey == "" {
+		return nil, errors.New("-tls-cert and -tls-key must be set together")
+	}
+
+	// Load now so a bad path or mismatched pair fails at startup
+	cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+//
+// --------------------
+// Tool filtering
+// --------------------
+//
+
+// RestrictTools hides tools from tools/list and refuses calls to them. A
+// non-empty enabled keeps only the tools it names, including ones
+// registered later; disabled then hides more. Both are comma-separated
+// lists of tool names. The names that match no registered tool are
+// returned, sorted, so callers can warn about typos.
+func (s *MCPServer) RestrictTools(enabled, disabled string) []string {
+	allow := toolNameSet(enabled)
+	deny := toolNameSet(disabled)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toolAllow = allow
+	s.toolDeny = deny
+
+	var unknown []string
+	for _, set := range []map[string]bool{allow, deny} {
+		for name := range set {
+			if _, ok := s.tools[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// toolNameSet splits a comma-separated list; it is nil when list is blank.
+func toolNameSet(list string) map[string]bool {
+	var set map[string]bool
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if set == nil {
+			set = make(map[string]bool)
+		}
+		set[name] = true
+	}
+	return set
+}
+
+// toolEnabled must be called with s.mu held.
+func (s *MCPServer) toolEnabled(name string) bool {
+	if s.toolAllow != nil && !s.toolAllow[name] {
+		return false
+	}
+	return !s.toolDeny[name]
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRestrictTools(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     string
+		disabled    string
+		hidden      []string
+		visible     []string
+		wantUnknown []string
+	}{
+		{
+			name:     "disabled list",
+			disabled: "calculate_gst, format_inr",
+			hidden:   []string{"calculate_gst", "format_inr"},
+			visible:  []string{"list_indian_stores", "get_store_details"},
+		},
+		{
+			name:    "enabled list",
+			enabled: "list_indian_stores,format_inr",
+			hidden:  []string{"calculate_gst", "get_store_details"},
+			visible: []string{"list_indian_stores", "format_inr"},
+		},
+		{
+			name:     "disabled applies after enabled",
+			enabled:  "list_indian_stores,format_inr",
+			disabled: "format_inr",
+			hidden:   []string{"format_inr"},
+			visible:  []string{"list_indian_stores"},
+		},
+		{
+			name:        "unknown names reported",
+			enabled:     "list_indian_stores,list_stores",
+			disabled:    "gst",
+			visible:     []string{"list_indian_stores"},
+			wantUnknown: []string{"gst", "list_stores"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			unknown := s.RestrictTools(tt.enabled, tt.disabled)
+			if !slices.Equal(unknown, tt.wantUnknown) {
+				t.Errorf("unknown = %v, want %v", unknown, tt.wantUnknown)
+			}
+
+			var listed []string
+			for _, tool := range s.listTools() {
+				listed = append(listed, tool.Name)
+			}
+			for _, name := range tt.visible {
+				if !slices.Contains(listed, name) {
+					t.Errorf("%s missing from tools/list %v", name, listed)
+				}
+			}
+			for _, name := range tt.hidden {
+				if slices.Contains(listed, name) {
+					t.Errorf("%s listed although restricted", name)
+				}
+				resp := callTool(t, s, ctx, name, map[string]interface{}{})
+				if resp.Error == nil || resp.Error.Code != -32601 {
+					t.Errorf("calling %s: error = %+v, want -32601", name, resp.Error)
+					continue
+				}
+				if data, ok := resp.Error.Data.(MethodErrorData); !ok || data.Method != name {
+					t.Errorf("calling %s: data = %#v, want MethodErrorData naming it", name, resp.Error.Data)
+				}
+			}
+		})
+	}
+}
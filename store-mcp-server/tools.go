@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//
+// --------------------
+// Tools
+// --------------------
+//
+
+// registerStoreTools adds the built-in store tools, backed by catalog, to s.
+// client is used to probe stores' websites and delivery answers
+// check_delivery.
+func registerStoreTools(s *MCPServer, catalog *StoreCatalog, client *http.Client, statusTimeout time.Duration, delivery DeliveryChecker) error {
+	if err := s.RegisterTool(Tool{
+		Name:        "list_indian_stores",
+		Description: "List popular Indian online stores as a JSON array of {name, url, category}",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"category": {Type: "string", Description: "Only list stores in this category"},
+				"limit":    {Type: "integer", Description: "Return at most this many stores; 0 means no limit", Minimum: new(float64)},
+			},
+		},
+		Cacheable: true,
+	}, catalog.listTool, "profile"); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "get_store_details",
+		Description: "Get the URL, category and a short description of one store",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"name": {Type: "string", Description: "Store name, e.g. Flipkart"},
+			},
+			Required: []string{"name"},
+		},
+		Cacheable: true,
+	}, catalog.detailsTool); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "check_store_status",
+		Description: "Check whether a store's website is reachable, with its HTTP status",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"name": {Type: "string", Description: "Store name, e.g. Flipkart"},
+			},
+			Required: []string{"name"},
+		},
+	}, catalog.statusTool(client, statusTimeout)); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "check_delivery",
+		Description: "Check whether a store delivers to an Indian pincode",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"store":   {Type: "string", Description: "Store name, e.g. Flipkart"},
+				"pincode": {Type: "string", Description: "6-digit Indian pincode, e.g. 560001", Pattern: pincodePattern},
+			},
+			Required: []string{"store", "pincode"},
+		},
+	}, catalog.deliveryTool(delivery)); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "export_catalog",
+		Description: "Export the whole store catalog as CSV (name, url, category, description), reporting progress per store",
+		InputSchema: InputSchema{Type: "object"},
+	}, catalog.exportTool); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "format_inr",
+		Description: "Format an amount in rupees with Indian digit grouping, e.g. 1234567 -> ₹12,34,567",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"amount": {Type: "number", Description: "Amount in rupees; may be negative or have paise"},
+			},
+			Required: []string{"amount"},
+		},
+		Cacheable: true,
+	}, formatINRTool); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "calculate_gst",
+		Description: "Work out GST on an amount in rupees: base, tax, total and the CGST/SGST split",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"amount":    {Type: "number", Description: "Amount in rupees", Minimum: new(float64)},
+				"rate":      {Type: "number", Description: "GST slab in percent", Enum: gstSlabs},
+				"inclusive": {Type: "boolean", Description: "Whether amount already includes GST; default false"},
+			},
+			Required: []string{"amount", "rate"},
+		},
+		Cacheable: true,
+	}, calculateGSTTool); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "store_search_url",
+		Description: "Get a link that opens a store's search results for a query; stores without search get their homepage",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"store": {Type: "string", Description: "Store name, e.g. Flipkart"},
+				"query": {Type: "string", Description: "What to search for, e.g. running shoes"},
+			},
+			Required: []string{"store", "query"},
+		},
+		Cacheable: true,
+	}, catalog.searchURLTool); err != nil {
+		return err
+	}
+
+	minCompared := 2
+	if err := s.RegisterTool(Tool{
+		Name:        "compare_stores",
+		Description: "Compare two or more stores side by side: URL, category and description of each",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"names": {
+					Type:        "array",
+					Description: "Store names, e.g. [\"Flipkart\", \"Amazon India\"]",
+					Items:       &Property{Type: "string"},
+					MinItems:    &minCompared,
+				},
+			},
+			Required: []string{"names"},
+		},
+		Cacheable: true,
+	}, catalog.compareTool); err != nil {
+		return err
+	}
+
+	if err := s.RegisterTool(Tool{
+		Name:        "list_categories",
+		Description: "List store categories with the number of stores in each, largest first",
+		InputSchema: InputSchema{Type: "object"},
+		Cacheable:   true,
+	}, catalog.categoriesTool); err != nil {
+		return err
+	}
+
+	return s.RegisterTool(Tool{
+		Name:        "search_stores",
+		Description: "Search stores whose name or category contains the query (case-insensitive)",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"query":    {Type: "string", Description: "Text to look for in store names and categories"},
+				"category": {Type: "string", Description: "Only return stores in this category"},
+			},
+			Required: []string{"query"},
+		},
+		Cacheable: true,
+	}, catalog.searchTool)
+}
+
+func (c *StoreCatalog) listTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	stores := c.All()
+	if category, _ := args["category"].(string); category != "" {
+		stores = c.Search("", category)
+	}
+	// The schema rules out negative limits
+	if limit, _ := args["limit"].(float64); limit > 0 && int(limit) < len(stores) {
+		stores = stores[:int(limit)]
+	}
+	return storesResult(stores)
+}
+
+// storesResult encodes stores as a JSON array in the first text block, for
+// programs, followed by a one-line summary for people.
+func storesResult(stores []Store) (CallToolResult, error) {
+	if stores == nil {
+		stores = []Store{}
+	}
+	data, err := json.Marshal(stores)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	return CallToolResult{
+		Content: []Content{
+			{Type: "text", Text: string(data)},
+			{Type: "text", Text: storesSummary(stores)},
+		},
+	}, nil
+}
+
+func storesSummary(stores []Store) string {
+	if len(stores) == 0 {
+		return "No stores matched."
+	}
+	names := make([]string, len(stores))
+	for i, store := range stores {
+		names[i] = store.Name
+	}
+	noun := "stores"
+	if len(stores) == 1 {
+		noun = "store"
+	}
+	return fmt.Sprintf("Found %d %s: %s", len(stores), noun, strings.Join(names, ", "))
+}
+
+// StoreComparison is the result of compare_stores: the stores found, in the
+// order asked for, and the names that matched no store.
+type StoreComparison struct {
+	Stores   []Store  `json:"stores"`
+	NotFound []string `json:"notFound,omitempty"`
+}
+
+// compareTool looks up each named store. Unknown names are reported rather
+// than failing the call, unless none of the names are known.
+func (c *StoreCatalog) compareTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	names, _ := args["names"].([]interface{})
+
+	cmp := StoreComparison{Stores: []Store{}}
+	var lines []string
+	for _, n := range names {
+		name, _ := n.(string)
+		store, ok := c.Find(name)
+		if !ok {
+			cmp.NotFound = append(cmp.NotFound, name)
+			continue
+		}
+		cmp.Stores = append(cmp.Stores, store)
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", store.Name, store.Category, store.URL))
+	}
+	if len(cmp.NotFound) > 0 {
+		lines = append(lines, "Not found: "+strings.Join(cmp.NotFound, ", "))
+	}
+
+	data, err := json.Marshal(cmp)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	return CallToolResult{
+		Content: []Content{
+			{Type: "text", Text: string(data)},
+			{Type: "text", Text: strings.Join(lines, "\n")},
+		},
+		IsError: len(cmp.Stores) == 0,
+	}, nil
+}
+
+// categoriesTool returns the category counts as a JSON array, followed by a
+// summary line. An empty catalog gives an empty array.
+func (c *StoreCatalog) categoriesTool(_ context.Context, _ map[string]interface{}) (CallToolResult, error) {
+	counts := c.CategoryCounts()
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+
+	summary := "No categories."
+	if len(counts) > 0 {
+		parts := make([]string, len(counts))
+		for i, cc := range counts {
+			parts[i] = fmt.Sprintf("%s (%d)", cc.Category, cc.Count)
+		}
+		summary = fmt.Sprintf("%d categories: %s", len(counts), strings.Join(parts, ", "))
+	}
+	return CallToolResult{
+		Content: []Content{
+			{Type: "text", Text: string(data)},
+			{Type: "text", Text: summary},
+		},
+	}, nil
+}
+
+func (c *StoreCatalog) searchTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	query, _ := args["query"].(string)
+	category, _ := args["category"].(string)
+	return storesResult(c.Search(query, category))
+}
+
+func (c *StoreCatalog) detailsTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	name, _ := args["name"].(string)
+
+	store, ok := c.Find(name)
+	if !ok {
+		return CallToolResult{
+			Content: []Content{{Type: "text", Text: "store not found: " + name}},
+			IsError: true,
+		}, nil
+	}
+
+	data, err := json.Marshal(store)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	return CallToolResult{
+		Content: []Content{
+			{Type: "text", Text: string(data)},
+			{Type: "text", Text: fmt.Sprintf("%s (%s): %s", store.Name, store.Category, store.URL)},
+		},
+	}, nil
+}
+
+// searchURLTool returns the store's search link as the first text block,
+// or its homepage with a note when the catalog has no search URL for it.
+func (c *StoreCatalog) searchURLTool(_ context.Context, args map[string]interface{}) (CallToolResult, error) {
+	name, _ := args["store"].(string)
+	query, _ := args["query"].(string)
+
+	store, ok := c.Find(name)
+	if !ok {
+		return CallToolResult{
+			Content: []Content{{Type: "text", Text: "store not found: " + name}},
+			IsError: true,
+		}, nil
+	}
+
+	link, ok := store.SearchLink(query)
+	if !ok {
+		return CallToolResult{
+			Content: []Content{
+				{Type: "text", Text: store.URL},
+				{Type: "text", Text: fmt.Sprintf("%s has no known search link; this is its homepage.", store.Name)},
+			},
+		}, nil
+	}
+	return CallToolResult{
+		Content: []Content{
+			{Type: "text", Text: link},
+			{Type: "text", Text: fmt.Sprintf("Search %s for %q", store.Name, query)},
+		},
+	}, nil
+}
+
+// exportTool writes the catalog as CSV one store at a time, so large
+// catalogs report progress and can be cancelled part way.
+func (c *StoreCatalog) exportTool(ctx context.Context, _ map[string]interface{}) (CallToolResult, error) {
+	stores := c.All()
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"name", "url", "category", "description"})
+
+	for i, store := range stores {
+		if err := ctx.Err(); err != nil {
+			return CallToolResult{}, err
+		}
+		w.Write([]string{store.Name, store.URL, store.Category, store.Description})
+		reportProgress(ctx, float64(i+1), float64(len(stores)), fmt.Sprintf("exported %s", store.Name))
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return CallToolResult{}, err
+	}
+	return CallToolResult{
+		Content: []Content{{Type: "text", Text: b.String()}},
+	}, nil
+}
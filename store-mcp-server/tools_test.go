@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStoreServer returns a server with the store tools registered over the
+// built-in catalog, and an initialized context to call them on.
+func newStoreServer(t *testing.T) (*MCPServer, context.Context) {
+	t.Helper()
+	s := NewMCPServer()
+	if err := registerStoreTools(s, DefaultStoreCatalog(), &http.Client{}, time.Second, StubDeliveryChecker{}); err != nil {
+		t.Fatalf("registerStoreTools: %v", err)
+	}
+	return s, initialized(t, s)
+}
+
+// callTool calls the named tool with args.
+func callTool(t *testing.T, s *MCPServer, ctx context.Context, name string, args map[string]interface{}) JSONRPCResponse {
+	t.Helper()
+	return call(t, s, ctx, "tools/call", map[string]interface{}{"name": name, "arguments": args})
+}
+
+func TestListStoresStructured(t *testing.T) {
+	s, ctx := newStoreServer(t)
+	result := toolResult(t, callTool(t, s, ctx, "list_indian_stores", nil))
+	if len(result.Content) != 2 {
+		t.Fatalf("got %d content blocks, want the JSON array and a summary", len(result.Content))
+	}
+
+	var stores []map[string]interface{}
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &stores); err != nil {
+		t.Fatalf("first block is not a JSON array: %v", err)
+	}
+	if len(stores) != DefaultStoreCatalog().Len() {
+		t.Errorf("got %d stores, want %d", len(stores), DefaultStoreCatalog().Len())
+	}
+	for _, store := range stores {
+		for _, key := range []string{"name", "url", "category"} {
+			if v, _ := store[key].(string); v == "" {
+				t.Errorf("store %v has no %s", store, key)
+			}
+		}
+	}
+	if !strings.HasPrefix(result.Content[1].Text, "Found ") {
+		t.Errorf("summary = %q", result.Content[1].Text)
+	}
+}
+
+func TestToolScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  *TokenClaims
+		tool    string
+		missing []string
+	}{
+		{"no token", nil, "list_indian_stores", nil},
+		{"has scope", &TokenClaims{Scope: "openid profile"}, "list_indian_stores", nil},
+		{"lacks scope", &TokenClaims{Scope: "openid"}, "list_indian_stores", []string{"profile"}},
+		{"empty scope", &TokenClaims{}, "list_indian_stores", []string{"profile"}},
+		{"tool needs none", &TokenClaims{}, "list_categories", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			if tt.claims != nil {
+				ctx = context.WithValue(ctx, claimsContextKey, tt.claims)
+			}
+			resp := callTool(t, s, ctx, tt.tool, nil)
+			if tt.missing == nil {
+				toolResult(t, resp)
+				return
+			}
+			if resp.Error == nil || resp.Error.Code != -32003 {
+				t.Fatalf("error = %+v, want -32003", resp.Error)
+			}
+			data, ok := resp.Error.Data.(ScopeErrorData)
+			if !ok {
+				t.Fatalf("data is %T, want ScopeErrorData", resp.Error.Data)
+			}
+			if !reflect.DeepEqual(data.Missing, tt.missing) {
+				t.Errorf("missing = %v, want %v", data.Missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestStoreToolsReturnJSONAndSummary(t *testing.T) {
+	tests := []struct {
+		tool    string
+		args    map[string]interface{}
+		summary string
+	}{
+		{"get_store_details", map[string]interface{}{"name": "Flipkart"}, "Flipkart ("},
+		{"search_stores", map[string]interface{}{"query": "flip"}, "Found 1 store: Flipkart"},
+		{"search_stores", map[string]interface{}{"query": "zzz"}, "No stores matched."},
+		{"list_categories", nil, " categories: "},
+		{"compare_stores", map[string]interface{}{"names": []string{"Flipkart", "Nowhere"}}, "Not found: Nowhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			result := toolResult(t, callTool(t, s, ctx, tt.tool, tt.args))
+			if len(result.Content) != 2 {
+				t.Fatalf("got %d content blocks, want 2", len(result.Content))
+			}
+			for i, c := range result.Content {
+				if c.Type != "text" {
+					t.Errorf("block %d has type %q, want text", i, c.Type)
+				}
+			}
+			if !json.Valid([]byte(result.Content[0].Text)) {
+				t.Errorf("first block is not JSON: %s", result.Content[0].Text)
+			}
+			if !strings.Contains(result.Content[1].Text, tt.summary) {
+				t.Errorf("summary = %q, want it to contain %q", result.Content[1].Text, tt.summary)
+			}
+		})
+	}
+}
+
+func TestToolsREST(t *testing.T) {
+	s, ctx := newStoreServer(t)
+	list, ok := call(t, s, ctx, "tools/list", nil).Result.(ToolsListResult)
+	if !ok {
+		t.Fatal("tools/list returned no ToolsListResult")
+	}
+	want, err := json.Marshal(list.Tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		status int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodPost, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			// No initialize handshake is needed
+			rec := httptest.NewRecorder()
+			s.handleToolsREST(rec, httptest.NewRequest(tt.method, "/tools", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := bytes.TrimSpace(rec.Body.Bytes()); !bytes.Equal(got, want) {
+				t.Errorf("GET /tools = %s\nwant tools/list's %s", got, want)
+			}
+		})
+	}
+}
+
+func TestListStoresFilters(t *testing.T) {
+	catalog := DefaultStoreCatalog()
+	marketplaces := len(catalog.Search("", "marketplace"))
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want int
+		code int
+	}{
+		{"no filters", nil, catalog.Len(), 0},
+		{"category", map[string]interface{}{"category": "marketplace"}, marketplaces, 0},
+		{"category is case-insensitive", map[string]interface{}{"category": "Marketplace"}, marketplaces, 0},
+		{"unknown category", map[string]interface{}{"category": "groceries"}, 0, 0},
+		{"limit", map[string]interface{}{"limit": 2}, 2, 0},
+		{"limit with category", map[string]interface{}{"category": "marketplace", "limit": 1}, 1, 0},
+		{"limit above count", map[string]interface{}{"limit": 100}, catalog.Len(), 0},
+		{"zero limit means none", map[string]interface{}{"limit": 0}, catalog.Len(), 0},
+		{"negative limit", map[string]interface{}{"limit": -1}, 0, -32602},
+		{"fractional limit", map[string]interface{}{"limit": 1.5}, 0, -32602},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ctx := newStoreServer(t)
+			resp := callTool(t, s, ctx, "list_indian_stores", tt.args)
+			if tt.code != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Errorf("error = %+v, want %d", resp.Error, tt.code)
+				}
+				return
+			}
+			var stores []Store
+			if err := json.Unmarshal([]byte(toolResult(t, resp).Content[0].Text), &stores); err != nil {
+				t.Fatal(err)
+			}
+			if len(stores) != tt.want {
+				t.Errorf("got %d stores, want %d", len(stores), tt.want)
+			}
+			if category, _ := tt.args["category"].(string); category != "" {
+				for _, store := range stores {
+					if !strings.EqualFold(store.Category, category) {
+						t.Errorf("%s is in %s, not %s", store.Name, store.Category, category)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSearchURLTool(t *testing.T) {
+	s, ctx := newStoreServer(t)
+	tests := []struct {
+		name    string
+		store   string
+		want    string
+		note    string
+		isError bool
+	}{
+		{"search template", "Flipkart", "https://www.flipkart.com/search?q=red+kurta", `Search Flipkart for "red kurta"`, false},
+		{"no template falls back to homepage", "Myntra", "https://www.myntra.com", "Myntra has no known search link; this is its homepage.", false},
+		{"unknown store", "Nowhere", "store not found: Nowhere", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := toolResult(t, callTool(t, s, ctx, "store_search_url", map[string]interface{}{"store": tt.store, "query": "red kurta"}))
+			if result.IsError != tt.isError || result.Content[0].Text != tt.want {
+				t.Fatalf("got %+v, want %q (isError %v)", result, tt.want, tt.isError)
+			}
+			if tt.note != "" && (len(result.Content) < 2 || result.Content[1].Text != tt.note) {
+				t.Errorf("note = %+v, want %q", result.Content[1:], tt.note)
+			}
+		})
+	}
+}
+
+func TestCompareStores(t *testing.T) {
+	s, ctx := newStoreServer(t)
+	tests := []struct {
+		name         string
+		names        []string
+		code         int
+		wantStores   []string
+		wantNotFound []string
+		isError      bool
+	}{
+		{"two stores", []string{"Flipkart", "Amazon India"}, 0, []string{"Flipkart", "Amazon India"}, nil, false},
+		{"order kept", []string{"Myntra", "Snapdeal", "Flipkart"}, 0, []string{"Myntra", "Snapdeal", "Flipkart"}, nil, false},
+		{"one unknown", []string{"Flipkart", "Nowhere"}, 0, []string{"Flipkart"}, []string{"Nowhere"}, false},
+		{"all unknown", []string{"Nowhere", "Elsewhere"}, 0, nil, []string{"Nowhere", "Elsewhere"}, true},
+		{"one name", []string{"Flipkart"}, -32602, nil, nil, false},
+		{"no names", []string{}, -32602, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := callTool(t, s, ctx, "compare_stores", map[string]interface{}{"names": tt.names})
+			if tt.code != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Fatalf("error = %+v, want %d", resp.Error, tt.code)
+				}
+				return
+			}
+			result := toolResult(t, resp)
+			if result.IsError != tt.isError {
+				t.Errorf("isError = %v, want %v", result.IsError, tt.isError)
+			}
+
+			var cmp StoreComparison
+			if err := json.Unmarshal([]byte(result.Content[0].Text), &cmp); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, store := range cmp.Stores {
+				if store.URL == "" || store.Category == "" {
+					t.Errorf("%s is missing its URL or category: %+v", store.Name, store)
+				}
+				got = append(got, store.Name)
+			}
+			if !reflect.DeepEqual(got, tt.wantStores) || !reflect.DeepEqual(cmp.NotFound, tt.wantNotFound) {
+				t.Errorf("stores %v, not found %v; want %v, %v", got, cmp.NotFound, tt.wantStores, tt.wantNotFound)
+			}
+		})
+	}
+}
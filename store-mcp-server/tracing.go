@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//
+// --------------------
+// Tracing
+// --------------------
+//
+
+const tracerName = "indian-store-mcp-server"
+
+// setupTracing exports spans over OTLP/HTTP to endpoint, e.g.
+// http://otel-collector:4318. With no endpoint the global no-op tracer
+// stays in place. The returned func flushes pending spans.
+func setupTracing(ctx context.Context, endpoint, serviceName string) (func(context.Context) error, error) {
+	// Propagate incoming trace context even when we don't export, so
+	// downstream calls could still join the caller's trace
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(Version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// traceRequests starts a server span per request, continuing the trace
+// named by an incoming traceparent header. It sits inside logRequests so
+// the span can carry the JSON-RPC method and error code found there.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if info := requestInfoFromContext(ctx); info != nil {
+			if info.RPCMethod != "" {
+				span.SetAttributes(attribute.String("rpc.method", info.RPCMethod))
+			}
+			if info.ErrorCode != 0 {
+				span.SetAttributes(attribute.Int("rpc.jsonrpc.error_code", info.ErrorCode))
+				span.SetStatus(codes.Error, "JSON-RPC error")
+			}
+		}
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
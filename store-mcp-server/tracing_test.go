@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceToolCall(t *testing.T) {
+	const (
+		traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+		traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+	tests := []struct {
+		name    string
+		err     error
+		outcome string
+	}{
+		{"success", nil, "success"},
+		{"failure", errors.New("upstream down"), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+			t.Cleanup(func() {
+				otel.SetTracerProvider(prevProvider)
+				otel.SetTextMapPropagator(prevPropagator)
+			})
+
+			s := NewMCPServer()
+			err := s.RegisterTool(Tool{Name: "echo", InputSchema: InputSchema{Type: "object"}},
+				func(context.Context, map[string]interface{}) (CallToolResult, error) {
+					return CallToolResult{Content: []Content{{Type: "text", Text: "ok"}}}, tt.err
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionID := postMCP(s, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`).Header().Get("Mcp-Session-Id")
+
+			req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo"}}`))
+			req.Header.Set("Mcp-Session-Id", sessionID)
+			req.Header.Set("traceparent", traceparent)
+			logRequests(traceRequests(http.HandlerFunc(s.handleMCPRequest))).ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("got %d spans, want the request and the tool call", len(spans))
+			}
+			tool, server := spans[0], spans[1]
+			if server.Name() != "POST /mcp" || tool.Name() != "tools/call echo" {
+				t.Fatalf("spans = %q, %q", server.Name(), tool.Name())
+			}
+			if got := server.SpanContext().TraceID().String(); got != traceID {
+				t.Errorf("trace id = %s, want the incoming %s", got, traceID)
+			}
+			if tool.Parent().SpanID() != server.SpanContext().SpanID() {
+				t.Error("tool span is not a child of the request span")
+			}
+
+			attrs := map[attribute.Key]string{}
+			for _, kv := range tool.Attributes() {
+				attrs[kv.Key] = kv.Value.Emit()
+			}
+			if attrs["mcp.tool.name"] != "echo" || attrs["mcp.tool.outcome"] != tt.outcome {
+				t.Errorf("tool span attributes = %v, want name echo and outcome %s", attrs, tt.outcome)
+			}
+		})
+	}
+}
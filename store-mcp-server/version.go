@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+//
+// --------------------
+// Build information
+// --------------------
+//
+
+// Version, Commit and BuildDate are set at build time, e.g.
+//
+//	go build -ldflags "-X main.Version=1.2.0 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+const defaultServerName = "indian-store-mcp-server"
+
+// versionString reports Version with the git commit appended when known.
+func versionString() string {
+	commit := buildCommit()
+	if commit == "" {
+		return Version
+	}
+	return Version + "+" + commit
+}
+
+// buildCommit returns Commit, falling back to the VCS stamp Go embeds in
+// binaries built from a checkout.
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	rev := vcsSetting("vcs.revision")
+	if len(rev) > 12 {
+		return rev[:12]
+	}
+	return rev
+}
+
+// buildDate returns BuildDate, falling back to the embedded commit time.
+func buildDate() string {
+	if BuildDate != "" {
+		return BuildDate
+	}
+	return vcsSetting("vcs.time")
+}
+
+func vcsSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == key {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// BuildInfo is the body of GET /version.
+type BuildInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+func currentBuildInfo(name string) BuildInfo {
+	info := BuildInfo{
+		Name:      name,
+		Version:   Version,
+		Commit:    buildCommit(),
+		BuildDate: buildDate(),
+		GoVersion: runtime.Version(),
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// versionHandler reports which build is running.
+func versionHandler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currentBuildInfo(name))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	tests := []struct {
+		name                      string
+		version, commit, date     string
+		wantCommit, wantBuildDate string
+	}{
+		{"ldflags", "1.2.0", "abc1234", "2026-01-02T03:04:05Z", "abc1234", "2026-01-02T03:04:05Z"},
+		// Test binaries carry no VCS stamp to fall back on
+		{"dev build", "dev", "", "", "unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := [3]string{Version, Commit, BuildDate}
+			t.Cleanup(func() { Version, Commit, BuildDate = saved[0], saved[1], saved[2] })
+			Version, Commit, BuildDate = tt.version, tt.commit, tt.date
+
+			rec := httptest.NewRecorder()
+			versionHandler("store").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var got BuildInfo
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := BuildInfo{
+				Name:      "store",
+				Version:   tt.version,
+				Commit:    tt.wantCommit,
+				BuildDate: tt.wantBuildDate,
+				GoVersion: runtime.Version(),
+			}
+			if got != want {
+				t.Errorf("GET /version = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//
+// --------------------
+// WebSocket transport
+// --------------------
+//
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// wsOriginCheck accepts handshakes without an Origin header (not from a
+// browser page), from the server's own origin, and from origins listed in
+// guard or cors. Browsers don't apply CORS to WebSockets, so a policy with
+// no origins configured allows nothing extra here rather than everything.
+func wsOriginCheck(guard *OriginGuard, cors *CORSPolicy) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return guard.allows(origin) || len(cors.Origins) > 0 && cors.allowOrigin(origin) != ""
+	}
+}
+
+// wsConn is one client speaking JSON-RPC over a WebSocket, one message per
+// text frame. Like stdio, the whole connection is one session.
+type wsConn struct {
+	server *MCPServer
+	conn   *websocket.Conn
+
+	writeMu sync.Mutex
+	calls   sync.WaitGroup
+}
+
+// webSocketHandler upgrades requests whose origin passes checkOrigin and
+// serves MCP on them. Frames larger than readLimit bytes close the
+// connection; 0 means no limit.
+func (s *MCPServer) webSocketHandler(checkOrigin func(*http.Request) bool, readLimit int64) http.HandlerFunc {
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin}
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an HTTP error
+			return
+		}
+		if readLimit > 0 {
+			conn.SetReadLimit(readLimit)
+		}
+		c := &wsConn{server: s, conn: conn}
+		c.serve(r)
+	}
+}
+
+func (c *wsConn) serve(r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer c.conn.Close()
+	defer cancel()
+	defer c.calls.Wait()
+
+	sess := newSession()
+	notify := func(n JSONRPCNotification) { c.send(n) }
+	ctx = withSession(ctx, sess)
+	ctx = withClientAddr(ctx, r)
+	ctx = withNotifier(ctx, notify)
+	defer c.server.subscribe(sess, notify)()
+
+	// Any frame from the client, pongs included, proves it's still there
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.keepalive(done)
+
+	for {
+		kind, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			if err == websocket.ErrReadLimit || websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("websocket: %v", err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		if kind != websocket.TextMessage {
+			continue
+		}
+
+		if isResponse(msg) {
+			continue
+		}
+		if isToolCall(msg) {
+			// Run in the background so a later notifications/cancelled
+			// can be read and reach it
+			c.calls.Add(1)
+			go func() {
+				defer c.calls.Done()
+				if resp, ok := c.server.handleMessage(ctx, msg); ok {
+					c.send(resp)
+				}
+			}()
+			continue
+		}
+		if resp, ok := c.server.handleMessage(ctx, msg); ok {
+			if err := c.send(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send writes one JSON message. It is safe to call from multiple goroutines.
+func (c *wsConn) send(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(v)
+}
+
+// keepalive pings the client so dead connections are noticed through the
+// read deadline.
+func (c *wsConn) keepalive(done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newWSServer(t *testing.T, readLimit int64) *httptest.Server {
+	t.Helper()
+	check := wsOriginCheck(NewOriginGuard("http://localhost"), NewCORSPolicy("https://app.example.com", "", "", 0))
+	srv := httptest.NewServer(NewMCPServer().webSocketHandler(check, readLimit))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialWS(srv *httptest.Server, origin string) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+}
+
+func TestWebSocketInitialize(t *testing.T) {
+	conn, _, err := dialWS(newWSServer(t, 0), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	steps := []struct {
+		send   string
+		wantID float64
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`, 1},
+		{`{"jsonrpc":"2.0","method":"notifications/initialized"}`, 0},
+		{`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, 2},
+	}
+	for _, step := range steps {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(step.send)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if step.wantID == 0 {
+			continue
+		}
+		var resp struct {
+			ID     float64                `json:"id"`
+			Result map[string]interface{} `json:"result"`
+			Error  *RPCError              `json:"error"`
+		}
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if resp.ID != step.wantID || resp.Error != nil || resp.Result == nil {
+			t.Errorf("reply to %s = %+v", step.send, resp)
+		}
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	srv := newWSServer(t, 0)
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{srv.URL, true},
+		{"http://localhost:3000", true},
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://localhost.evil.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			conn, resp, err := dialWS(srv, tt.origin)
+			if err == nil {
+				conn.Close()
+			}
+			if got := err == nil; got != tt.want {
+				t.Errorf("handshake accepted = %v, want %v", got, tt.want)
+			}
+			if !tt.want && (resp == nil || resp.StatusCode != http.StatusForbidden) {
+				t.Errorf("rejected handshake response = %v, want 403", resp)
+			}
+		})
+	}
+}
+
+func TestWebSocketReadLimit(t *testing.T) {
+	conn, _, err := dialWS(newWSServer(t, 64), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	big := `{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", 128) + `"}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(big)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("read after oversized frame: %v, want close 1009", err)
+	}
+}